@@ -0,0 +1,71 @@
+package syshealth
+
+import "time"
+
+// AgentInfo describes the agent sending metrics
+type AgentInfo struct {
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+	// Interval is the polling rate of the agent (in seconds)
+	Interval int `json:"interval"`
+}
+
+// Agent represents the state of the agent of a server, as seen by the server
+type Agent struct {
+	AgentInfo
+	LastSeen time.Time `json:"last_seen"`
+	// ClockSkew is the difference (in seconds) between the clock of the server and the clock of the agent
+	ClockSkew float64 `json:"clock_skew"`
+	// Skewed is true when the clock skew is greater than `MaxClockSkew`
+	Skewed bool `json:"skewed"`
+}
+
+// MaxClockSkew is the clock skew above which the clock of an agent is reported as wrong
+const MaxClockSkew = time.Duration(30) * time.Second
+
+// ClockSkew returns the difference between the clock of the server and the clock
+// of the agent, estimated from the date the metrics were sent (the network delay is included)
+func (bag MetricBag) ClockSkew(receivedAt time.Time) time.Duration {
+	if bag.SentAt.IsZero() {
+		return 0
+	}
+	return receivedAt.Sub(bag.SentAt)
+}
+
+// AgentState returns the state of the agent which sent the metrics
+func (bag MetricBag) AgentState(receivedAt time.Time) Agent {
+	skew := bag.ClockSkew(receivedAt)
+
+	agent := Agent{
+		LastSeen:  receivedAt,
+		ClockSkew: skew.Seconds(),
+		Skewed:    skew > MaxClockSkew || skew < -MaxClockSkew,
+	}
+	if bag.Agent != nil {
+		agent.AgentInfo = *bag.Agent
+	}
+
+	return agent
+}
+
+// CollectedAt returns the collection date of the metrics, in the clock of the server.
+// The age of the metrics, measured by the agent, does not depend on its clock skew.
+func (bag MetricBag) CollectedAt(receivedAt time.Time) time.Time {
+	if bag.Date.IsZero() {
+		return receivedAt
+	}
+
+	if !bag.SentAt.IsZero() {
+		age := bag.SentAt.Sub(bag.Date)
+		if age < 0 {
+			age = 0
+		}
+		return receivedAt.Add(-age)
+	}
+
+	// agents not sending the date of the request are trusted, unless the date is in the future
+	if bag.Date.After(receivedAt) {
+		return receivedAt
+	}
+	return bag.Date
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 	"webup/syshealth"
 
@@ -61,6 +62,9 @@ type slackPayloadAttachmentField struct {
 
 var webhookURL string
 
+// flappingColor is used for alerts about levels changing too often
+const flappingColor = "#439FE0"
+
 func InitSlackAlerter(URL string) {
 	webhookURL = URL
 }
@@ -108,12 +112,22 @@ func SendSlackAlert(alert syshealth.Alert) error {
 }
 
 func getPayload(alert syshealth.Alert) slackPayload {
-	return slackPayload{
+
+	title := alert.IssueTitle
+	color := getSlackColorForLevel(alert.Level)
+	label := alert.Level.Label()
+	if alert.Flapping {
+		title = alert.IssueTitle + " is flapping"
+		color = flappingColor
+		label = "Flapping"
+	}
+
+	payload := slackPayload{
 		Attachments: []slackPayloadAttachment{
 			slackPayloadAttachment{
-				Title:    alert.IssueTitle,
-				Color:    getSlackColorForLevel(alert.Level),
-				Fallback: fmt.Sprintf("%v: %s on '%v' (%v)", alert.Level.Label(), alert.IssueTitle, alert.Server.Name, alert.Server.IP),
+				Title:    title,
+				Color:    color,
+				Fallback: fmt.Sprintf("%v: %s on '%v' (%v)", label, alert.IssueTitle, alert.Server.Name, alert.Server.IP),
 				Fields: []slackPayloadAttachmentField{
 					slackPayloadAttachmentField{
 						Title: "Server",
@@ -127,13 +141,45 @@ func getPayload(alert syshealth.Alert) slackPayload {
 					},
 					slackPayloadAttachmentField{
 						Title: "Level",
-						Value: alert.Level.Label(),
+						Value: label,
 						Short: true,
 					},
 				},
 			},
 		},
 	}
+
+	if alert.Description != "" {
+		payload.Attachments[0].Fields = append(payload.Attachments[0].Fields, slackPayloadAttachmentField{
+			Title: "Details",
+			Value: alert.Description,
+		})
+	}
+
+	// top processes snapshot
+	if alert.Processes != nil {
+		fields := []slackPayloadAttachmentField{
+			slackPayloadAttachmentField{
+				Title: "Top CPU",
+				Value: formatProcesses(alert.Processes.CPU),
+			},
+			slackPayloadAttachmentField{
+				Title: "Top memory",
+				Value: formatProcesses(alert.Processes.Memory),
+			},
+		}
+		payload.Attachments[0].Fields = append(payload.Attachments[0].Fields, fields...)
+	}
+
+	return payload
+}
+
+func formatProcesses(processes []syshealth.Process) string {
+	lines := []string{}
+	for _, p := range processes {
+		lines = append(lines, fmt.Sprintf("%d %v (%v): %.1f%% CPU, %.0fMB", p.PID, p.Name, p.User, p.CPU, p.Memory))
+	}
+	return strings.Join(lines, "\n")
 }
 
 func getSlackColorForLevel(level syshealth.ThresholdLevel) string {
@@ -0,0 +1,64 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+)
+
+// stats is an exponentially weighted mean and variance.
+// The first values have the same weight, then the weight of new values
+// is bounded by `minWeight` so old values are progressively forgotten.
+type stats struct {
+	Mean     float64
+	Variance float64
+	Count    float64
+}
+
+func (s *stats) add(value float64, minWeight float64) {
+	s.Count++
+	weight := math.Max(1/s.Count, minWeight)
+
+	diff := value - s.Mean
+	s.Mean += weight * diff
+	s.Variance = (1 - weight) * (s.Variance + weight*diff*diff)
+}
+
+func (s *stats) stddev() float64 {
+	return math.Sqrt(s.Variance)
+}
+
+// baseline learns the normal values of a metric, globally and for each hour of the day
+type baseline struct {
+	Global stats
+	Hours  [24]stats
+	// LastPoint is the date of the last learned history point
+	LastPoint time.Time
+}
+
+const (
+	// the global baseline mostly depends on the last day of values (one value per minute)
+	globalMinWeight = 1.0 / (24 * 60)
+	// hourly baselines mostly depend on the last week
+	hourMinWeight = 1.0 / (7 * 60)
+	// number of values required before using baselines
+	minGlobalCount = 60
+	minHourCount   = 120
+)
+
+func (b *baseline) learn(date time.Time, value float64) {
+	b.Global.add(value, globalMinWeight)
+	b.Hours[date.Hour()].add(value, hourMinWeight)
+	b.LastPoint = date
+}
+
+// expected returns the statistics to use for a value at the given date.
+// The hourly profile is used when enough values are known for the hour.
+func (b *baseline) expected(date time.Time) (*stats, bool) {
+	if hour := &b.Hours[date.Hour()]; hour.Count >= minHourCount {
+		return hour, true
+	}
+	if b.Global.Count >= minGlobalCount {
+		return &b.Global, true
+	}
+	return nil, false
+}
@@ -0,0 +1,146 @@
+package anomaly
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/alert"
+	"webup/syshealth/history"
+)
+
+// metrics whose history is analysed
+var analysedMetrics = []string{"cpu.usage", "memory.used_percent"}
+
+const (
+	// a value is anomalous when it deviates from the mean by `maxDeviation` standard deviations
+	maxDeviation = 3.0
+	// and by `minDifference` at least, to ignore small variations of very stable metrics
+	minDifference = 10.0
+	// number of consecutive anomalous values (one per minute) before sending an alert
+	minAnomalousCount = 3
+	// minimal duration between two alerts for the same metric
+	alertInterval = time.Duration(30) * time.Minute
+)
+
+type watcher struct {
+	fetcher           history.DataFetcher
+	baselinesByServer map[string]map[string]*baseline
+	statesByServer    map[string]map[string]*anomalyState
+	mutex             sync.Mutex
+}
+
+type anomalyState struct {
+	Count         int
+	LastSentAlert time.Time
+	// Last is the last anomalous value, described in alerts
+	Last anomaly
+}
+
+// anomaly is a value deviating from the statistics of its baseline
+type anomaly struct {
+	Value  float64
+	Mean   float64
+	Stddev float64
+}
+
+// describe returns the observed value, and the expected one
+func (a anomaly) describe(metric string) string {
+	description := fmt.Sprintf("%v is %.1f, expected %.1f ± %.1f", metric, a.Value, a.Mean, a.Stddev)
+	if a.Stddev > 0 {
+		description += fmt.Sprintf(" (deviation of %.1f standard deviations)", math.Abs(a.Value-a.Mean)/a.Stddev)
+	}
+	return description
+}
+
+// NewWatcher returns a watcher learning the usual values of metrics for each server
+// from their history, and sending a warning when a value deviates significantly
+func NewWatcher(fetcher history.DataFetcher) syshealth.Watcher {
+	w := watcher{
+		fetcher:           fetcher,
+		baselinesByServer: map[string]map[string]*baseline{},
+		statesByServer:    map[string]map[string]*anomalyState{},
+	}
+	return &w
+}
+
+func (w *watcher) GetKey() syshealth.WatcherKey {
+	return "anomaly"
+}
+
+func (w *watcher) Watch(data syshealth.WatcherData) {
+
+	// replayed metrics do not describe the current state
+	if data.IsHistorical() {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	id := data.Server.ID
+	if _, ok := w.baselinesByServer[id]; !ok {
+		w.baselinesByServer[id] = map[string]*baseline{}
+		w.statesByServer[id] = map[string]*anomalyState{}
+	}
+
+	serverHistory := w.fetcher(id)
+
+	for _, metric := range analysedMetrics {
+		b, ok := w.baselinesByServer[id][metric]
+		if !ok {
+			b = new(baseline)
+			w.baselinesByServer[id][metric] = b
+			w.statesByServer[id][metric] = new(anomalyState)
+		}
+		state := w.statesByServer[id][metric]
+
+		// each new point is compared with the baseline, then learned
+		for _, p := range serverHistory[metric] {
+			value, ok := history.FloatValue(p.Value)
+			if !ok || math.IsNaN(value) || !p.Date.After(b.LastPoint) {
+				continue
+			}
+
+			if a, ok := detect(b, p.Date, value); ok {
+				state.Count++
+				state.Last = a
+			} else {
+				state.Count = 0
+			}
+
+			b.learn(p.Date, value)
+		}
+
+		if state.Count >= minAnomalousCount && time.Now().Sub(state.LastSentAlert) >= alertInterval {
+			log.Printf("anomaly.%v(%v): anomaly detected\n", metric, data.Server.Name)
+
+			err := alert.SendSlackAlert(syshealth.Alert{
+				IssueTitle:  "anomaly." + metric,
+				Server:      data.Server,
+				Level:       syshealth.Warning,
+				Description: state.Last.describe(metric),
+				Processes:   syshealth.GetTopProcesses(data.Metrics),
+			})
+			if err != nil {
+				log.Println("cannot send alert:", err)
+			}
+
+			state.LastSentAlert = time.Now()
+		}
+	}
+}
+
+// detect returns the anomaly if the value deviates from the baseline
+func detect(b *baseline, date time.Time, value float64) (anomaly, bool) {
+	expected, ok := b.expected(date)
+	if !ok {
+		return anomaly{}, false
+	}
+
+	a := anomaly{Value: value, Mean: expected.Mean, Stddev: expected.stddev()}
+	difference := math.Abs(value - expected.Mean)
+	return a, difference >= minDifference && difference >= maxDeviation*a.Stddev
+}
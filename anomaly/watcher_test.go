@@ -0,0 +1,41 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetect(t *testing.T) {
+	b := new(baseline)
+	date := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < minGlobalCount; i++ {
+		b.learn(date, float64(20+i%5))
+		date = date.Add(time.Minute)
+	}
+
+	if _, ok := detect(b, date, 23); ok {
+		t.Error("a usual value must not be anomalous")
+	}
+
+	a, ok := detect(b, date, 95)
+	if !ok {
+		t.Fatal("a value far from the mean must be anomalous")
+	}
+	if a.Value != 95 || a.Mean < 21 || a.Mean > 23 || a.Stddev <= 0 {
+		t.Errorf("unexpected anomaly: %+v", a)
+	}
+
+	expected := "cpu.usage is 95.0, expected 22.0 ± 1.4 (deviation of 51.6 standard deviations)"
+	if description := a.describe("cpu.usage"); description != expected {
+		t.Errorf("got description %q, expected %q", description, expected)
+	}
+}
+
+func TestDetectWithoutBaseline(t *testing.T) {
+	b := new(baseline)
+	b.learn(time.Now(), 10)
+
+	if _, ok := detect(b, time.Now(), 95); ok {
+		t.Error("no anomaly must be detected before the baseline is learned")
+	}
+}
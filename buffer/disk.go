@@ -0,0 +1,151 @@
+package buffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+const fileExtension = ".json"
+
+// NewDiskQueue returns a queue storing at most `size` metrics in a directory,
+// one file by collection, so that metrics survive a restart of the agent
+func NewDiskQueue(dir string, size int) (Queue, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create buffer directory")
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read buffer directory")
+	}
+
+	q := diskQueue{dir: dir, size: size, files: []string{}}
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), fileExtension) {
+			q.files = append(q.files, info.Name())
+		}
+	}
+	// file names are ordered by collection date
+	sort.Strings(q.files)
+	if len(q.files) > 0 {
+		fmt.Sscanf(q.files[len(q.files)-1], "%d", &q.sequence)
+	}
+
+	return &q, q.trim()
+}
+
+type diskQueue struct {
+	dir   string
+	size  int
+	files []string
+	// sequence keeps file names ordered
+	sequence uint64
+	mutex    sync.Mutex
+}
+
+func (q *diskQueue) Push(bag syshealth.MetricBag) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	content, err := json.Marshal(bag)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode metrics")
+	}
+
+	q.sequence++
+	name := fmt.Sprintf("%020d%v", q.sequence, fileExtension)
+
+	// the file is renamed once written, a partial file is never read
+	tmp := filepath.Join(q.dir, name+".tmp")
+	err = ioutil.WriteFile(tmp, content, 0600)
+	if err != nil {
+		return errors.Wrap(err, "cannot write metrics in buffer")
+	}
+	err = os.Rename(tmp, filepath.Join(q.dir, name))
+	if err != nil {
+		return errors.Wrap(err, "cannot write metrics in buffer")
+	}
+
+	q.files = append(q.files, name)
+
+	return q.trim()
+}
+
+func (q *diskQueue) Peek(n int) ([]syshealth.MetricBag, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	bags := []syshealth.MetricBag{}
+	for i := 0; i < len(q.files) && len(bags) < n; {
+		content, err := ioutil.ReadFile(filepath.Join(q.dir, q.files[i]))
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read metrics from buffer")
+		}
+
+		bag := syshealth.MetricBag{}
+		err = json.Unmarshal(content, &bag)
+		if err == nil {
+			bags = append(bags, bag)
+			i++
+			continue
+		}
+
+		// corrupted files are dropped
+		log.Printf("dropping invalid buffer file %v: %v\n", q.files[i], err)
+		if err := q.remove(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return bags, nil
+}
+
+func (q *diskQueue) Pop(n int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for ; n > 0 && len(q.files) > 0; n-- {
+		if err := q.remove(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *diskQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.files)
+}
+
+// remove deletes the i-th file
+func (q *diskQueue) remove(i int) error {
+	err := os.Remove(filepath.Join(q.dir, q.files[i]))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "cannot remove metrics from buffer")
+	}
+	q.files = append(q.files[:i], q.files[i+1:]...)
+	return nil
+}
+
+// trim drops the oldest files when the queue is full
+func (q *diskQueue) trim() error {
+	for len(q.files) > q.size {
+		if err := q.remove(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package buffer
+
+import (
+	"sync"
+	"webup/syshealth"
+)
+
+// Queue stores the metrics waiting to be sent, in collection order.
+// When the queue is full, the oldest metrics are dropped.
+type Queue interface {
+	// Push adds metrics at the end of the queue
+	Push(bag syshealth.MetricBag) error
+	// Peek returns at most `n` metrics, from the oldest ones
+	Peek(n int) ([]syshealth.MetricBag, error)
+	// Pop removes the `n` oldest metrics
+	Pop(n int) error
+	// Len returns the count of metrics in the queue
+	Len() int
+}
+
+// NewMemoryQueue returns a queue storing at most `size` metrics in memory
+func NewMemoryQueue(size int) Queue {
+	return &memoryQueue{
+		size: size,
+		bags: []syshealth.MetricBag{},
+	}
+}
+
+type memoryQueue struct {
+	size  int
+	bags  []syshealth.MetricBag
+	mutex sync.Mutex
+}
+
+func (q *memoryQueue) Push(bag syshealth.MetricBag) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.bags = append(q.bags, bag)
+	if len(q.bags) > q.size {
+		q.bags = q.bags[len(q.bags)-q.size:]
+	}
+
+	return nil
+}
+
+func (q *memoryQueue) Peek(n int) ([]syshealth.MetricBag, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if n > len(q.bags) {
+		n = len(q.bags)
+	}
+	return append([]syshealth.MetricBag{}, q.bags[:n]...), nil
+}
+
+func (q *memoryQueue) Pop(n int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if n > len(q.bags) {
+		n = len(q.bags)
+	}
+	q.bags = q.bags[n:]
+	return nil
+}
+
+func (q *memoryQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.bags)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"time"
+	"webup/syshealth/config"
+	"webup/syshealth/http"
+	"webup/syshealth/metrics"
+
+	"github.com/pkg/errors"
+)
+
+// default schedules of the collectors, the polling rate is used for other collectors
+var defaultSchedules = map[string]metrics.Schedule{
+	// partitions usage changes slowly
+	"disk": {Interval: time.Minute},
+	// stats of each container take 1-2s to be computed by Docker
+	"docker": {Interval: time.Duration(30) * time.Second, Timeout: time.Duration(20) * time.Second},
+}
+
+const (
+	// default interval of the checks, plugins may be expensive
+	defaultCheckInterval = time.Minute
+	// default schedule of the probes
+	defaultProbeInterval = time.Minute
+	defaultProbeTimeout  = time.Duration(10) * time.Second
+)
+
+// agent contains the collectors and the client built from a configuration
+type agent struct {
+	config   config.Config
+	registry *metrics.Registry
+	client   *http.Client
+	cancel   context.CancelFunc
+}
+
+// newAgent builds the collectors and the client, the collectors are not started.
+// The client of the previous agent, if any, is reconfigured so that its backoff state is kept.
+func newAgent(cfg config.Config, client *http.Client) (*agent, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	matchers := []metrics.ProcessMatcher{}
+	for _, definition := range cfg.Processes {
+		matcher, err := metrics.ParseProcessMatcher(definition)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+
+	// optional collectors are enabled by their settings
+	registry := metrics.NewRegistry(metrics.Schedule{Interval: cfg.PollingRate})
+	registry.Register(metrics.NewCPUCollector(), true)
+	registry.Register(metrics.NewMemoryCollector(), true)
+	registry.Register(metrics.NewDiskCollector(), true)
+	registry.Register(metrics.NewPressureCollector(), true)
+	registry.Register(metrics.NewDiskIOCollector(), true)
+	registry.Register(metrics.NewNetworkCollector(cfg.Network.Include, cfg.Network.Exclude), true)
+	registry.Register(metrics.NewTopProcessesCollector(cfg.TopProcesses), cfg.TopProcesses > 0)
+	registry.Register(metrics.NewProcessMatchersCollector(matchers), len(matchers) > 0)
+	registry.Register(metrics.NewSystemdCollector(cfg.Systemd.Units), cfg.Systemd.Enabled)
+	registry.Register(metrics.NewDockerCollector(cfg.Docker.Socket), cfg.Docker.Enabled)
+	registry.Register(metrics.NewCgroupCollector(cfg.Cgroups), len(cfg.Cgroups) > 0)
+
+	schedules := map[string]metrics.Schedule{}
+	for name, schedule := range defaultSchedules {
+		schedules[name] = schedule
+	}
+
+	for _, check := range cfg.Checks {
+		collector := metrics.NewCheckCollector(check.Name, check.Command)
+		registry.Register(collector, true)
+
+		schedule := metrics.Schedule{Interval: defaultCheckInterval, Timeout: check.Timeout}
+		if check.Interval > 0 {
+			schedule.Interval = check.Interval
+		}
+		schedules[collector.Name()] = schedule
+	}
+
+	for _, definition := range cfg.Probes {
+		probe := metrics.Probe{
+			Name:               definition.Name,
+			URL:                definition.URL,
+			Method:             definition.Method,
+			ExpectedStatus:     definition.ExpectedStatus,
+			InsecureSkipVerify: definition.InsecureSkipVerify,
+		}
+		if definition.BodyRegex != "" {
+			probe.Body, err = regexp.Compile(definition.BodyRegex)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid body regex of the probe '%v'", definition.Name)
+			}
+		}
+
+		collector := metrics.NewProbeCollector(probe)
+		registry.Register(collector, true)
+
+		schedule := metrics.Schedule{Interval: defaultProbeInterval, Timeout: defaultProbeTimeout}
+		if definition.Interval > 0 {
+			schedule.Interval = definition.Interval
+		}
+		if definition.Timeout > 0 {
+			schedule.Timeout = definition.Timeout
+		}
+		schedules[collector.Name()] = schedule
+	}
+
+	for name, schedule := range schedules {
+		if err := registry.SetSchedule(name, schedule); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, collector := range cfg.Collectors {
+		if collector.Enabled != nil {
+			if err := registry.Enable(name, *collector.Enabled); err != nil {
+				return nil, err
+			}
+		}
+
+		schedule := schedules[name]
+		if collector.Interval > 0 {
+			schedule.Interval = collector.Interval
+		}
+		if collector.Timeout > 0 {
+			schedule.Timeout = collector.Timeout
+		}
+		if err := registry.SetSchedule(name, schedule); err != nil {
+			return nil, err
+		}
+	}
+
+	tlsConfig, err := cfg.TLS.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.NewClient(cfg.ServerURL, cfg.JWT, cfg.Buffer.Compress, tlsConfig)
+	} else {
+		client.Configure(cfg.ServerURL, cfg.JWT, cfg.Buffer.Compress, tlsConfig)
+	}
+
+	return &agent{
+		config:   cfg,
+		registry: registry,
+		client:   client,
+	}, nil
+}
+
+// start runs the collectors
+func (a *agent) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.registry.Run(ctx)
+}
+
+// stop stops the collectors
+func (a *agent) stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
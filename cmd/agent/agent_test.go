@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"webup/syshealth/metrics"
+)
+
+// durations of the test are divided by this scale, so that it runs quickly
+const dockerTestScale = 100
+
+func TestDockerDefaultSchedule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a few dozen running containers, whose stats take 1.5s to be computed
+	containers := []map[string]interface{}{}
+	for i := 0; i < 48; i++ {
+		containers = append(containers, map[string]interface{}{
+			"Id":    fmt.Sprintf("c%d", i),
+			"Names": []string{fmt.Sprintf("/c%d", i)},
+			"State": "running",
+		})
+	}
+	statsDelay := time.Duration(1500) * time.Millisecond / dockerTestScale
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/containers/json":
+			json.NewEncoder(w).Encode(containers)
+		case strings.HasSuffix(r.URL.Path, "/stats"):
+			time.Sleep(statsDelay)
+			fmt.Fprint(w, `{"memory_stats": {"usage": 1048576, "limit": 2097152}}`)
+		default:
+			fmt.Fprint(w, `{"RestartCount": 0}`)
+		}
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	schedule := defaultSchedules["docker"]
+	if schedule.Timeout <= 0 || schedule.Timeout > schedule.Interval {
+		t.Fatalf("invalid default schedule %+v", schedule)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), schedule.Timeout/dockerTestScale)
+	defer cancel()
+
+	data, err := metrics.NewDockerCollector(socket).Collect(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, container := range data["docker.containers"].(map[string]interface{}) {
+		if message, ok := container.(map[string]interface{})["error"]; ok {
+			t.Errorf("container %v was not collected within the default timeout: %v", name, message)
+		}
+	}
+}
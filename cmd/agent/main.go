@@ -3,25 +3,40 @@ package main
 import (
 	"fmt"
 	"log"
+	"math/rand"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	"webup/syshealth"
+	"webup/syshealth/buffer"
+	"webup/syshealth/config"
 	"webup/syshealth/http"
+	"webup/syshealth/metrics"
 
 	"os"
 
 	"github.com/jawher/mow.cli"
+	"github.com/pkg/errors"
 )
 
+const version = "v2.0 (build 2)"
+
 func main() {
 
 	app := cli.App("syshealth-agent", "Syshealth agent gathering host metrics (requires syshealth-server v1.x")
 
-	app.Version("v version", "syshealth-agent v2.0 (build 2)")
+	app.Version("v version", "syshealth-agent "+version)
 
-	app.Spec = "--jwt --server-url [--polling-rate]"
+	app.Spec = "[--config] [--jwt] [--server-url] [--polling-rate] [--net-include...] [--net-exclude...] [--top-processes] [--process...] [--systemd] [--systemd-unit...] [--docker] [--docker-socket] [--cgroup...] [--enable-collector...] [--disable-collector...] [--collector-interval...] [--collector-timeout...] [--buffer-size] [--buffer-dir] [--batch-size] [--compress] [--remote-config-rate]"
 
 	var (
+		configFile = app.String(cli.StringOpt{
+			Name:   "config",
+			Desc:   "Path of the YAML configuration file, its settings override the flags (reloaded on SIGHUP)",
+			Value:  "",
+			EnvVar: "SYSHEALTH_AGENT_CONFIG",
+		})
 		jwt = app.String(cli.StringOpt{
 			Name:   "jwt",
 			Desc:   "JWT token given by the server after server registration",
@@ -35,27 +50,361 @@ func main() {
 			EnvVar: "SYSHEALTH_AGENT_SERVER_URL",
 		})
 		pollingRate = app.IntOpt("polling-rate", 5, "Polling rate for gathering metrics (in seconds)")
+		netInclude  = app.Strings(cli.StringsOpt{
+			Name:   "net-include",
+			Desc:   "Patterns of the network interfaces to watch (i.e. eth*), all interfaces if empty",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_NET_INCLUDE",
+		})
+		netExclude = app.Strings(cli.StringsOpt{
+			Name:   "net-exclude",
+			Desc:   "Patterns of the network interfaces to ignore",
+			Value:  []string{"lo"},
+			EnvVar: "SYSHEALTH_AGENT_NET_EXCLUDE",
+		})
+		topProcesses = app.Int(cli.IntOpt{
+			Name:   "top-processes",
+			Desc:   "Number of processes sent by CPU and by memory usage (0 to disable)",
+			Value:  0,
+			EnvVar: "SYSHEALTH_AGENT_TOP_PROCESSES",
+		})
+		processes = app.Strings(cli.StringsOpt{
+			Name:  "process",
+			Desc:  "Processes to count, defined as 'label:key=value;key=value' with keys name, cmdline (regex), user, min and max (i.e. 'nginx:name=nginx;min=1')",
+			Value: []string{},
+		})
+		systemd = app.Bool(cli.BoolOpt{
+			Name:   "systemd",
+			Desc:   "Report the state of systemd units (failed units and units given with --systemd-unit)",
+			Value:  false,
+			EnvVar: "SYSHEALTH_AGENT_SYSTEMD",
+		})
+		systemdUnits = app.Strings(cli.StringsOpt{
+			Name:   "systemd-unit",
+			Desc:   "Systemd units to report (i.e. nginx.service)",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_SYSTEMD_UNITS",
+		})
+		docker = app.Bool(cli.BoolOpt{
+			Name:   "docker",
+			Desc:   "Report the state and resources usage of Docker containers",
+			Value:  false,
+			EnvVar: "SYSHEALTH_AGENT_DOCKER",
+		})
+		dockerSocket = app.String(cli.StringOpt{
+			Name:   "docker-socket",
+			Desc:   "Path of the Docker socket",
+			Value:  "/var/run/docker.sock",
+			EnvVar: "SYSHEALTH_AGENT_DOCKER_SOCKET",
+		})
+		cgroups = app.Strings(cli.StringsOpt{
+			Name:   "cgroup",
+			Desc:   "Paths of cgroups (v2) to report, relative to /sys/fs/cgroup (i.e. system.slice/*)",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_CGROUPS",
+		})
+		enabledCollectors = app.Strings(cli.StringsOpt{
+			Name:   "enable-collector",
+			Desc:   "Collectors to enable (i.e. processes)",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_ENABLED_COLLECTORS",
+		})
+		disabledCollectors = app.Strings(cli.StringsOpt{
+			Name:   "disable-collector",
+			Desc:   "Collectors to disable (i.e. network)",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_DISABLED_COLLECTORS",
+		})
+		collectorIntervals = app.Strings(cli.StringsOpt{
+			Name:   "collector-interval",
+			Desc:   "Interval of a collector, defined as 'name=duration' (i.e. 'disk=60s'), the polling rate by default",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_COLLECTOR_INTERVALS",
+		})
+		collectorTimeouts = app.Strings(cli.StringsOpt{
+			Name:   "collector-timeout",
+			Desc:   "Timeout of a collector, defined as 'name=duration' (i.e. 'docker=20s'), the interval by default",
+			Value:  []string{},
+			EnvVar: "SYSHEALTH_AGENT_COLLECTOR_TIMEOUTS",
+		})
+		bufferSize = app.Int(cli.IntOpt{
+			Name:   "buffer-size",
+			Desc:   "Maximal count of metrics kept while the server is unreachable",
+			Value:  720,
+			EnvVar: "SYSHEALTH_AGENT_BUFFER_SIZE",
+		})
+		bufferDir = app.String(cli.StringOpt{
+			Name:   "buffer-dir",
+			Desc:   "Directory where metrics are kept while the server is unreachable, in memory if empty",
+			Value:  "",
+			EnvVar: "SYSHEALTH_AGENT_BUFFER_DIR",
+		})
+		batchSize = app.Int(cli.IntOpt{
+			Name:   "batch-size",
+			Desc:   "Count of metrics sent in a single request (metrics are sent every batch-size * polling-rate seconds)",
+			Value:  1,
+			EnvVar: "SYSHEALTH_AGENT_BATCH_SIZE",
+		})
+		compress = app.Bool(cli.BoolOpt{
+			Name:   "compress",
+			Desc:   "Compress metrics with gzip",
+			Value:  false,
+			EnvVar: "SYSHEALTH_AGENT_COMPRESS",
+		})
+		remoteConfigRate = app.Int(cli.IntOpt{
+			Name:   "remote-config-rate",
+			Desc:   "Rate for getting the configuration pushed by the server, which overrides the configuration file (in seconds, 0 to disable)",
+			Value:  60,
+			EnvVar: "SYSHEALTH_AGENT_REMOTE_CONFIG_RATE",
+		})
 	)
 
+	// flagsConfig returns the configuration given by flags
+	flagsConfig := func() (config.Config, error) {
+		cfg := config.Config{
+			ServerURL:    *serverURL,
+			JWT:          *jwt,
+			PollingRate:  time.Duration(*pollingRate) * time.Second,
+			Collectors:   map[string]config.Collector{},
+			Network:      config.Network{Include: *netInclude, Exclude: *netExclude},
+			TopProcesses: *topProcesses,
+			Processes:    *processes,
+			Systemd:      config.Systemd{Enabled: *systemd, Units: *systemdUnits},
+			Docker:       config.Docker{Enabled: *docker, Socket: *dockerSocket},
+			Cgroups:      *cgroups,
+			Buffer:       config.Buffer{Size: *bufferSize, Dir: *bufferDir, BatchSize: *batchSize, Compress: *compress},
+		}
+
+		for _, name := range *enabledCollectors {
+			enabled := true
+			collector := cfg.Collectors[name]
+			collector.Enabled = &enabled
+			cfg.Collectors[name] = collector
+		}
+		for _, name := range *disabledCollectors {
+			enabled := false
+			collector := cfg.Collectors[name]
+			collector.Enabled = &enabled
+			cfg.Collectors[name] = collector
+		}
+
+		intervals, err := parseDurations(*collectorIntervals)
+		if err != nil {
+			return cfg, err
+		}
+		for name, interval := range intervals {
+			collector := cfg.Collectors[name]
+			collector.Interval = interval
+			cfg.Collectors[name] = collector
+		}
+
+		timeouts, err := parseDurations(*collectorTimeouts)
+		if err != nil {
+			return cfg, err
+		}
+		for name, timeout := range timeouts {
+			collector := cfg.Collectors[name]
+			collector.Timeout = timeout
+			cfg.Collectors[name] = collector
+		}
+
+		return cfg, nil
+	}
+
+	// loadConfig returns the configuration given by flags and by the configuration file
+	loadConfig := func(path string) (config.Config, error) {
+		cfg, err := flagsConfig()
+		if err != nil || path == "" {
+			return cfg, err
+		}
+		return config.Load(path, cfg)
+	}
+
+	app.Command("validate-config", "Check the configuration given by flags and by the configuration file", func(cmd *cli.Cmd) {
+
+		cmd.Spec = "[FILE]"
+
+		file := cmd.StringArg("FILE", "", "Path of the configuration file, the one given with --config by default")
+
+		cmd.Action = func() {
+			path := *file
+			if path == "" {
+				path = *configFile
+			}
+
+			cfg, err := loadConfig(path)
+			if err == nil {
+				_, err = newAgent(cfg, nil)
+			}
+			if err != nil {
+				fmt.Println("invalid configuration:", err)
+				cli.Exit(1)
+			}
+
+			fmt.Println("configuration is valid")
+		}
+	})
+
 	app.Action = func() {
 
+		rand.Seed(time.Now().UnixNano())
+
+		cfg, err := loadConfig(*configFile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		current, err := newAgent(cfg, nil)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		// remote is the last valid configuration pushed by the server, applied over the configuration file.
+		// It is fetched before starting the collectors, so that they are not restarted at once.
+		var remote []byte
+		remoteETag := ""
+		if *remoteConfigRate > 0 {
+			content, etag, err := current.client.GetConfig("")
+			if err != nil {
+				log.Println("cannot get configuration from server:", err)
+			} else {
+				remoteETag = etag
+				var next *agent
+				cfg, err := config.Apply(content, cfg)
+				if err == nil {
+					next, err = newAgent(cfg, current.client)
+				}
+				if err != nil {
+					log.Println("invalid configuration pushed by the server, the local one is used:", err)
+				} else {
+					current = next
+					remote = content
+				}
+			}
+		}
+
+		queue := buffer.NewMemoryQueue(cfg.Buffer.Size)
+		if cfg.Buffer.Dir != "" {
+			queue, err = buffer.NewDiskQueue(cfg.Buffer.Dir, cfg.Buffer.Size)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Println("cannot get hostname:", err)
+		}
+
+		current.start()
+
 		sigs := make(chan os.Signal, 1)
+		reloads := make(chan os.Signal, 1)
 		done := make(chan bool, 1)
 
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		signal.Notify(reloads, syscall.SIGHUP)
 
 		go func() {
-			ticker := time.NewTicker(time.Duration(*pollingRate) * time.Second)
+			// agents started at the same time (i.e. after a server restart) do not send their metrics together
+			select {
+			case <-time.After(time.Duration(rand.Int63n(int64(current.config.PollingRate)))):
+			case <-sigs:
+				current.stop()
+				done <- true
+				return
+			}
+
+			ticker := time.NewTicker(current.config.PollingRate)
+
+			// reload replaces the agent if the configuration is valid
+			reload := func(remoteConfig []byte) error {
+				cfg, err := loadConfig(*configFile)
+				if err == nil && remoteConfig != nil {
+					cfg, err = config.Apply(remoteConfig, cfg)
+				}
+				if err == nil && (cfg.Buffer.Size != current.config.Buffer.Size || cfg.Buffer.Dir != current.config.Buffer.Dir) {
+					log.Println("buffer settings are applied on restart")
+					cfg.Buffer.Size = current.config.Buffer.Size
+					cfg.Buffer.Dir = current.config.Buffer.Dir
+				}
+
+				var next *agent
+				if err == nil {
+					next, err = newAgent(cfg, current.client)
+				}
+				if err != nil {
+					return err
+				}
+
+				current.stop()
+				next.start()
+				current = next
+
+				ticker.Stop()
+				ticker = time.NewTicker(current.config.PollingRate)
+
+				return nil
+			}
+
+			// fetchRemote applies the configuration pushed by the server, if it changed
+			fetchRemote := func() {
+				content, etag, err := current.client.GetConfig(remoteETag)
+				if err != nil {
+					log.Println("cannot get configuration from server:", err)
+					return
+				}
+				if content == nil {
+					return
+				}
+
+				// an invalid configuration is not fetched again until it changes
+				remoteETag = etag
+				err = reload(content)
+				if err != nil {
+					log.Println("invalid configuration pushed by the server, the current one is kept:", err)
+					return
+				}
+				remote = content
+				log.Println("configuration pushed by the server applied")
+			}
+
+			var remoteTicks <-chan time.Time
+			if *remoteConfigRate > 0 {
+				remoteTicker := time.NewTicker(time.Duration(*remoteConfigRate) * time.Second)
+				defer remoteTicker.Stop()
+				remoteTicks = remoteTicker.C
+			}
 
 			for {
 				select {
 				case <-ticker.C:
-					err := http.SendData(*serverURL, *jwt)
+					data := current.registry.Snapshot()
+					if failures, ok := data[metrics.ErrorsKey]; ok {
+						log.Println("error collecting metrics:", failures)
+					}
+
+					// metrics are buffered, then sent in collection order
+					info := syshealth.AgentInfo{Hostname: hostname, Version: version, Interval: int(current.config.PollingRate.Seconds())}
+					err := queue.Push(syshealth.MetricBag{Metrics: data, Date: time.Now(), Agent: &info})
 					if err != nil {
-						log.Println("error sending data:", err)
+						log.Println("cannot buffer metrics:", err)
 					}
+					flush(current.client, queue, current.config.Buffer.BatchSize)
+
+				case <-remoteTicks:
+					fetchRemote()
+
+				case <-reloads:
+					err := reload(remote)
+					if err != nil {
+						log.Println("invalid configuration, the current one is kept:", err)
+						continue
+					}
+					log.Println("configuration reloaded")
+
 				case <-sigs:
 					ticker.Stop()
+					current.stop()
 					done <- true
 				}
 			}
@@ -70,3 +419,50 @@ func main() {
 	app.Run(os.Args)
 
 }
+
+// flush sends the buffered metrics by batches, until the server is unreachable.
+// Metrics are kept until a batch is complete, or while the client waits after a failure.
+func flush(client *http.Client, queue buffer.Queue, batchSize int) {
+	for queue.Len() >= batchSize && !time.Now().Before(client.NextAttempt()) {
+		bags, err := queue.Peek(batchSize)
+		if err != nil {
+			log.Println("cannot read buffered metrics:", err)
+			return
+		}
+		if len(bags) == 0 {
+			return
+		}
+
+		err = client.SendData(bags)
+		if http.IsRetryable(err) {
+			log.Printf("error sending data (%d metrics buffered): %v\n", queue.Len(), err)
+			return
+		}
+		if err != nil {
+			log.Println("error sending data, metrics dropped:", err)
+		}
+
+		err = queue.Pop(len(bags))
+		if err != nil {
+			log.Println("cannot remove buffered metrics:", err)
+			return
+		}
+	}
+}
+
+// parseDurations parses definitions like `name=duration` (i.e. disk=60s)
+func parseDurations(definitions []string) (map[string]time.Duration, error) {
+	durations := map[string]time.Duration{}
+	for _, definition := range definitions {
+		kv := strings.SplitN(definition, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid definition '%v': must be name=duration", definition)
+		}
+		d, err := time.ParseDuration(kv[1])
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("invalid duration in '%v'", definition)
+		}
+		durations[kv[0]] = d
+	}
+	return durations, nil
+}
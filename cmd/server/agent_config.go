@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"webup/syshealth"
+	"webup/syshealth/config"
+	"webup/syshealth/metrics"
+
+	"github.com/pkg/errors"
+)
+
+// scopes of the configurations pushed to agents, the configuration
+// of a server overrides the configuration of its group
+const (
+	groupScope  = "group:"
+	serverScope = "server:"
+)
+
+// validateAgentConfig checks a configuration before it is pushed to agents
+func validateAgentConfig(scope string, content string) error {
+	if !strings.HasPrefix(scope, groupScope) && !strings.HasPrefix(scope, serverScope) {
+		return errors.Errorf("the scope must start with '%v' or '%v'", groupScope, serverScope)
+	}
+
+	remote, err := config.ParseRemote([]byte(content))
+	if err != nil {
+		return err
+	}
+	if remote.Processes != nil {
+		for _, definition := range *remote.Processes {
+			if _, err := metrics.ParseProcessMatcher(definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// agentConfig returns the configuration pushed to the agent of the server,
+// with its ETag (a hash of the configuration)
+func agentConfig(repo syshealth.AgentConfigRepository, server syshealth.Server) ([]byte, string, error) {
+	documents := [][]byte{}
+
+	scopes := []string{serverScope + server.ID}
+	if server.Group != "" {
+		scopes = []string{groupScope + server.Group, serverScope + server.ID}
+	}
+	for _, scope := range scopes {
+		content, err := repo.Get(scope)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "unable to get configuration of '%v'", scope)
+		}
+		documents = append(documents, []byte(content))
+	}
+
+	merged, err := config.MergeRemote(documents...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return merged, fmt.Sprintf(`"%x"`, sha256.Sum256(merged)), nil
+}
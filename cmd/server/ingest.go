@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"webup/syshealth"
+
+	"github.com/labstack/echo"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maximal size of the body sent by an agent, compressed or not
+	maxBodySize = 5 * 1024 * 1024
+	// maximal size of the body once decompressed
+	maxDecodedSize = 20 * 1024 * 1024
+	// maximal count of metrics sent in a single request
+	maxBatchSize = 500
+)
+
+// readMetricBags decodes the metrics sent by an agent, as a single bag or an array
+// of bags, compressed with gzip if the `Content-Encoding` header is set
+func readMetricBags(c echo.Context) ([]syshealth.MetricBag, error) {
+	body, err := readLimited(c.Request().Body, maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding := strings.ToLower(c.Request().Header.Get(echo.HeaderContentEncoding)); encoding {
+	case "", "identity":
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "invalid gzip body"))
+		}
+		defer reader.Close()
+
+		body, err = readLimited(reader, maxDecodedSize)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, echo.NewHTTPError(http.StatusUnsupportedMediaType, "unsupported content encoding '"+encoding+"'")
+	}
+
+	bags := []syshealth.MetricBag{}
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		err = json.Unmarshal(body, &bags)
+	} else {
+		bag := syshealth.MetricBag{}
+		err = json.Unmarshal(body, &bag)
+		bags = append(bags, bag)
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to parse json body"))
+	}
+
+	if len(bags) > maxBatchSize {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "too many metrics in a single request")
+	}
+
+	return bags, nil
+}
+
+// readLimited reads at most `limit` bytes, and fails if there is more to read
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to read body"))
+	}
+	if int64(len(b)) > limit {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "body too large")
+	}
+	return b, nil
+}
@@ -12,9 +12,11 @@ import (
 	"time"
 	"webup/syshealth"
 	"webup/syshealth/alert"
+	"webup/syshealth/anomaly"
 	"webup/syshealth/history"
 	"webup/syshealth/repository/bolt"
 	"webup/syshealth/repository/memory"
+	"webup/syshealth/rule"
 	"webup/syshealth/threshold"
 	"webup/syshealth/watcher"
 
@@ -36,7 +38,7 @@ func main() {
 
 	app.Command("daemon", "Start the API listening for metrics and serving the UI", func(cmd *cli.Cmd) {
 
-		cmd.Spec = "[--listening-ip] [--listening-port] [--agent-jwt-secret] [--client-jwt-secret] [--slack-webhook-url] [--database-directory]"
+		cmd.Spec = "[--listening-ip] [--listening-port] [--agent-jwt-secret] [--client-jwt-secret] [--slack-webhook-url] [--database-directory] [--disk-full-horizon] [--rules-file]"
 
 		listeningIP := cmd.String(cli.StringOpt{
 			Name:   "listening-ip",
@@ -80,20 +82,53 @@ func main() {
 			Desc:   "Directory path where the database file will be stored",
 			EnvVar: "SYSHEALTH_DATABASE_DIRECTORY",
 		})
+		diskFullHorizon := cmd.String(cli.StringOpt{
+			Name:   "disk-full-horizon",
+			Value:  "24h",
+			Desc:   "A warning is sent when a partition is predicted to be full within this duration",
+			EnvVar: "SYSHEALTH_DISK_FULL_HORIZON",
+		})
+		rulesFile := cmd.String(cli.StringOpt{
+			Name:   "rules-file",
+			Value:  "",
+			Desc:   "Path of a JSON file defining custom threshold rules",
+			EnvVar: "SYSHEALTH_RULES_FILE",
+		})
 
 		cmd.Action = func() {
 
 			adminUserRepo := bolt.GetAdminUserRepository(*databaseDirectory)
 			serverRepo := bolt.GetServerRepository(*databaseDirectory)
+			agentConfigRepo := bolt.GetAgentConfigRepository(*databaseDirectory)
 			metricRepo := memory.GetMetricRepository()
+			agentRepo := memory.GetAgentRepository()
 
 			alert.InitSlackAlerter(*slackWebhookURL)
 
+			horizon, err := time.ParseDuration(*diskFullHorizon)
+			if err != nil {
+				log.Fatalln(errors.Wrap(err, "invalid disk full horizon"))
+				return
+			}
+
+			rules := []rule.Rule{}
+			if *rulesFile != "" {
+				rules, err = rule.Load(*rulesFile, threshold.TriggerKeys())
+				if err != nil {
+					log.Fatalln(errors.Wrap(err, "invalid rules file"))
+					return
+				}
+			}
+
 			// prepare watchers
 			historyWatcher, historyFetcher := history.NewWatcher()
 			watchers := []syshealth.Watcher{
-				threshold.NewWatcher(),
+				threshold.NewWatcher(historyFetcher, threshold.Config{
+					DiskFullHorizon: horizon,
+					Rules:           rules,
+				}),
 				historyWatcher,
+				anomaly.NewWatcher(historyFetcher),
 			}
 			// start the watcher process
 			receivedDataForWatchers := watcher.Start(watchers)
@@ -138,6 +173,8 @@ func main() {
 			// endpoint used by agents to send their metrics
 			e.POST("/api/metrics", func(c echo.Context) error {
 
+				receivedAt := time.Now()
+
 				token := c.Get("user").(*jwt.Token)
 				claims := token.Claims.(jwt.MapClaims)
 				id := claims["jti"].(string)
@@ -151,26 +188,79 @@ func main() {
 					return c.NoContent(http.StatusUnauthorized)
 				}
 
-				// parse data
-				data := syshealth.MetricBag{}
-				err = c.Bind(&data)
+				// parse data (agents may send several metrics at once)
+				bags, err := readMetricBags(c)
 				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to parse json body"))
+					return err
+				}
+				if len(bags) == 0 {
+					return c.NoContent(http.StatusOK)
 				}
 
-				// store data
-				err = metricRepo.Store(id, data.Metrics)
+				// metrics of a request are sent together, the agent state is the same
+				agent := bags[len(bags)-1].AgentState(receivedAt)
+				previous, err := agentRepo.Get(id)
 				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to store metric"))
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to get agent"))
+				}
+				if agent.Skewed && (previous == nil || !previous.Skewed) {
+					log.Printf("%v: agent clock is skewed by %.0fs\n", server.Name, agent.ClockSkew)
 				}
+				err = agentRepo.Store(id, agent)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to store agent"))
+				}
+
+				for _, data := range bags {
+					// metrics replayed by the agent after an outage keep their collection date,
+					// corrected with the clock skew of the agent
+					watcherData := syshealth.WatcherData{Server: *server, Metrics: data.Metrics, Date: data.CollectedAt(receivedAt), ReceivedAt: receivedAt}
 
-				// send data for triggers
-				receivedDataForWatchers <- syshealth.WatcherData{Server: *server, Metrics: data.Metrics}
+					// store data (only the latest metrics are stored)
+					if !watcherData.IsHistorical() {
+						err = metricRepo.Store(id, data.Metrics)
+						if err != nil {
+							return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to store metric"))
+						}
+					}
+
+					// send data for triggers
+					receivedDataForWatchers <- watcherData
+				}
 
 				return c.NoContent(http.StatusOK)
 
 			}, agentJwtMiddleware)
 
+			// endpoint used by agents to get the configuration of their server and its group
+			e.GET("/api/agent/config", func(c echo.Context) error {
+
+				token := c.Get("user").(*jwt.Token)
+				claims := token.Claims.(jwt.MapClaims)
+				id := claims["jti"].(string)
+
+				server, err := serverRepo.GetServer(id)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to check if server is registered"))
+				}
+				if server == nil {
+					return c.NoContent(http.StatusUnauthorized)
+				}
+
+				content, etag, err := agentConfig(agentConfigRepo, *server)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to get agent configuration"))
+				}
+
+				c.Response().Header().Set("ETag", etag)
+				if c.Request().Header.Get("If-None-Match") == etag {
+					return c.NoContent(http.StatusNotModified)
+				}
+
+				return c.Blob(http.StatusOK, "application/x-yaml", content)
+
+			}, agentJwtMiddleware)
+
 			// authentication endpoint for API clients (not agents)
 			e.POST("/api/login", func(c echo.Context) error {
 
@@ -278,6 +368,10 @@ func main() {
 				type metric struct {
 					Server serverData      `json:"server"`
 					Data   *syshealth.Data `json:"data"`
+					// estimated time (in seconds) before each partition is full
+					DiskFullIn map[string]float64 `json:"disk_full_in"`
+					// Agent is the state of the agent, nil if it has never sent metrics
+					Agent *syshealth.Agent `json:"agent"`
 				}
 
 				metrics := []metric{}
@@ -287,9 +381,21 @@ func main() {
 						log.Println(errors.Wrap(err, "unable to get data for registered server"))
 					}
 
+					agent, err := agentRepo.Get(server.ID)
+					if err != nil {
+						log.Println(errors.Wrap(err, "unable to get agent of registered server"))
+					}
+
+					diskFullIn := map[string]float64{}
+					for mountpoint, remaining := range history.DiskFullForecast(historyFetcher(server.ID)) {
+						diskFullIn[mountpoint] = remaining.Seconds()
+					}
+
 					metrics = append(metrics, metric{
-						Server: serverData{Server: server, DefaultPartition: "/"},
-						Data:   data,
+						Server:     serverData{Server: server, DefaultPartition: "/"},
+						Data:       data,
+						DiskFullIn: diskFullIn,
+						Agent:      agent,
 					})
 				}
 
@@ -373,6 +479,88 @@ func main() {
 				return c.NoContent(http.StatusOK)
 			}, clientJwtMiddleware)
 
+			e.PUT("/api/servers/:id/group", func(c echo.Context) error {
+
+				id := c.Param("id")
+
+				data := struct {
+					Group string `json:"group"`
+				}{}
+
+				err := c.Bind(&data)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to get json data"))
+				}
+
+				server, err := serverRepo.GetServer(id)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to get server"))
+				}
+				if server == nil {
+					return c.NoContent(http.StatusNotFound)
+				}
+
+				err = serverRepo.SetServerGroup(id, data.Group)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to change server group"))
+				}
+
+				return c.NoContent(http.StatusOK)
+			}, clientJwtMiddleware)
+
+			// configurations pushed to agents, by scope (i.e. `group:web` or `server:<id>`)
+			e.GET("/api/agent-configs", func(c echo.Context) error {
+
+				configs, err := agentConfigRepo.GetAll()
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to fetch agent configurations"))
+				}
+
+				data := map[string]interface{}{
+					"configs": configs,
+				}
+
+				return c.JSON(http.StatusOK, data)
+			}, clientJwtMiddleware)
+
+			e.PUT("/api/agent-configs/:scope", func(c echo.Context) error {
+
+				scope := c.Param("scope")
+
+				data := struct {
+					Config string `json:"config"`
+				}{}
+
+				err := c.Bind(&data)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "unable to get json data"))
+				}
+
+				err = validateAgentConfig(scope, data.Config)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "invalid agent configuration"))
+				}
+
+				err = agentConfigRepo.Set(scope, data.Config)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to store agent configuration"))
+				}
+
+				return c.NoContent(http.StatusOK)
+			}, clientJwtMiddleware)
+
+			e.DELETE("/api/agent-configs/:scope", func(c echo.Context) error {
+
+				scope := c.Param("scope")
+
+				err := agentConfigRepo.Delete(scope)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusInternalServerError, errors.Wrap(err, "unable to delete agent configuration"))
+				}
+
+				return c.NoContent(http.StatusOK)
+			}, clientJwtMiddleware)
+
 			// private API for support tasks (i.e. backups...)
 			go func() {
 				privateAPI := echo.New()
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"path"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the configuration of the agent, given by flags and by a YAML file
+type Config struct {
+	ServerURL   string        `yaml:"server_url"`
+	JWT         string        `yaml:"jwt"`
+	PollingRate time.Duration `yaml:"polling_rate"`
+	// Collectors contains the settings of each collector, by name
+	Collectors   map[string]Collector `yaml:"collectors"`
+	Network      Network              `yaml:"network"`
+	TopProcesses int                  `yaml:"top_processes"`
+	// Processes are the definitions of the process matchers (i.e. `nginx:name=nginx;min=1`)
+	Processes []string `yaml:"processes"`
+	Systemd   Systemd  `yaml:"systemd"`
+	Docker    Docker   `yaml:"docker"`
+	// Cgroups are the paths of the cgroups to report, relative to /sys/fs/cgroup
+	Cgroups []string `yaml:"cgroups"`
+	// Checks are commands compatible with Nagios plugins
+	Checks []Check `yaml:"checks"`
+	// Probes are HTTP(S) requests sent by the agent
+	Probes []Probe `yaml:"probes"`
+	Buffer Buffer  `yaml:"buffer"`
+	TLS    TLS     `yaml:"tls"`
+}
+
+// Collector contains the settings of a collector. Zero values keep the defaults.
+type Collector struct {
+	Enabled  *bool         `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Check contains the settings of a command compatible with Nagios plugins
+type Check struct {
+	Name string `yaml:"name"`
+	// Command is the executable then its arguments, it is not run by a shell
+	Command  []string      `yaml:"command"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+// Probe contains the settings of a HTTP(S) request and its expected response
+type Probe struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Method string `yaml:"method"`
+	// ExpectedStatus is the status of the response, any status below 400 if 0
+	ExpectedStatus int `yaml:"expected_status"`
+	// BodyRegex is a regular expression the body of the response must match
+	BodyRegex          string        `yaml:"body_regex"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+}
+
+// Network contains the patterns (i.e. eth*) of the network interfaces to watch
+type Network struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// Systemd contains the settings of the systemd collector
+type Systemd struct {
+	Enabled bool     `yaml:"enabled"`
+	Units   []string `yaml:"units"`
+}
+
+// Docker contains the settings of the Docker collector
+type Docker struct {
+	Enabled bool   `yaml:"enabled"`
+	Socket  string `yaml:"socket"`
+}
+
+// Buffer contains the settings of the buffer of metrics waiting to be sent
+type Buffer struct {
+	Size      int    `yaml:"size"`
+	Dir       string `yaml:"dir"`
+	BatchSize int    `yaml:"batch_size"`
+	Compress  bool   `yaml:"compress"`
+}
+
+// TLS contains the settings of the connection to the server
+type TLS struct {
+	// CAFile is the certificate of the authority of the server certificate, system authorities are used if empty
+	CAFile string `yaml:"ca_file"`
+	// CertFile and KeyFile are the client certificate, if required by the server
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Load reads the YAML file and applies its settings on the given configuration
+func Load(path string, config Config) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, errors.Wrap(err, "cannot read configuration file")
+	}
+
+	// unknown keys are rejected, so that typos are not ignored
+	err = yaml.UnmarshalStrict(content, &config)
+	if err != nil {
+		return config, errors.Wrapf(err, "invalid configuration file '%v'", path)
+	}
+
+	return config, nil
+}
+
+// Validate checks the settings which are not checked when they are used
+func (c Config) Validate() error {
+	if c.JWT == "" {
+		return errors.New("the JWT is required")
+	}
+	if c.ServerURL == "" {
+		return errors.New("the server URL is required")
+	}
+	if c.PollingRate <= 0 {
+		return errors.New("the polling rate must be positive")
+	}
+	if c.Buffer.BatchSize < 1 || c.Buffer.BatchSize > c.Buffer.Size {
+		return errors.New("the batch size must be between 1 and the buffer size")
+	}
+	// the first metrics of a batch are sent about `batch size * polling rate` after their collection,
+	// the server does not raise alerts for metrics received later than the maximal live delay
+	if time.Duration(c.Buffer.BatchSize)*c.PollingRate > syshealth.MaxLiveDelay {
+		return errors.Errorf("the batch size multiplied by the polling rate must not exceed %v", syshealth.MaxLiveDelay)
+	}
+	for _, patterns := range [][]string{c.Network.Include, c.Network.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Errorf("invalid network interface pattern '%v'", pattern)
+			}
+		}
+	}
+	for name, collector := range c.Collectors {
+		if collector.Interval < 0 || collector.Timeout < 0 {
+			return errors.Errorf("the interval and the timeout of the collector '%v' must be positive", name)
+		}
+	}
+	names := map[string]bool{}
+	for _, check := range c.Checks {
+		if check.Name == "" || len(check.Command) == 0 {
+			return errors.New("the name and the command of the checks are required")
+		}
+		if names[check.Name] {
+			return errors.Errorf("the check '%v' is defined twice", check.Name)
+		}
+		names[check.Name] = true
+		if check.Interval < 0 || check.Timeout < 0 {
+			return errors.Errorf("the interval and the timeout of the check '%v' must be positive", check.Name)
+		}
+	}
+	names = map[string]bool{}
+	for _, probe := range c.Probes {
+		if probe.Name == "" || probe.URL == "" {
+			return errors.New("the name and the URL of the probes are required")
+		}
+		if names[probe.Name] {
+			return errors.Errorf("the probe '%v' is defined twice", probe.Name)
+		}
+		names[probe.Name] = true
+		if probe.Interval < 0 || probe.Timeout < 0 {
+			return errors.Errorf("the interval and the timeout of the probe '%v' must be positive", probe.Name)
+		}
+	}
+	return nil
+}
+
+// TLSConfig returns the TLS configuration of the connection to the server
+func (t TLS) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CAFile != "" {
+		ca, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read CA file")
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("no certificate found in CA file '%v'", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBatchDelay(t *testing.T) {
+	tests := []struct {
+		batchSize   int
+		pollingRate time.Duration
+		err         string
+	}{
+		{1, time.Duration(5) * time.Second, ""},
+		{24, time.Duration(5) * time.Second, ""},
+		{2, time.Minute, ""},
+		{30, time.Duration(5) * time.Second, "the batch size multiplied by the polling rate must not exceed 2m0s"},
+		{3, time.Minute, "the batch size multiplied by the polling rate must not exceed 2m0s"},
+	}
+
+	for _, test := range tests {
+		c := Config{ServerURL: "https://server", JWT: "token", PollingRate: test.pollingRate}
+		c.Buffer.Size = 720
+		c.Buffer.BatchSize = test.batchSize
+
+		err := c.Validate()
+		if test.err == "" && err != nil {
+			t.Errorf("batch of %d every %v: unexpected error: %v", test.batchSize, test.pollingRate, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("batch of %d every %v: got error %v, expected %q", test.batchSize, test.pollingRate, err, test.err)
+		}
+	}
+}
+
+func TestValidateNetworkPatterns(t *testing.T) {
+	tests := []struct {
+		network Network
+		err     string
+	}{
+		{Network{Include: []string{"eth*", "en?[0-9]"}, Exclude: []string{"lo"}}, ""},
+		{Network{Exclude: []string{"lo", "docker[0-9"}}, "invalid network interface pattern 'docker[0-9'"},
+		{Network{Include: []string{"eth\\"}}, "invalid network interface pattern 'eth\\'"},
+	}
+
+	for _, test := range tests {
+		c := Config{ServerURL: "https://server", JWT: "token", PollingRate: time.Duration(5) * time.Second, Network: test.network}
+		c.Buffer.Size = 720
+		c.Buffer.BatchSize = 1
+
+		err := c.Validate()
+		if test.err == "" && err != nil {
+			t.Errorf("%+v: unexpected error: %v", test.network, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%+v: got error %v, expected %q", test.network, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Remote contains the settings which may be pushed by the server to agents,
+// with the same keys as the configuration file. Settings are merged key by key:
+// unset keys keep the values of the previous scopes or of the configuration file,
+// lists are replaced (an empty list clears the list).
+type Remote struct {
+	PollingRate  time.Duration              `yaml:"polling_rate,omitempty"`
+	Collectors   map[string]RemoteCollector `yaml:"collectors,omitempty"`
+	Network      *RemoteNetwork             `yaml:"network,omitempty"`
+	TopProcesses *int                       `yaml:"top_processes,omitempty"`
+	Processes    *[]string                  `yaml:"processes,omitempty"`
+	Systemd      *RemoteSystemd             `yaml:"systemd,omitempty"`
+	Cgroups      *[]string                  `yaml:"cgroups,omitempty"`
+}
+
+// RemoteCollector contains the settings of a collector pushed by the server
+type RemoteCollector struct {
+	Enabled  *bool         `yaml:"enabled,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"`
+}
+
+// RemoteNetwork contains the patterns of the network interfaces pushed by the server
+type RemoteNetwork struct {
+	Include *[]string `yaml:"include,omitempty"`
+	Exclude *[]string `yaml:"exclude,omitempty"`
+}
+
+// RemoteSystemd contains the settings of the systemd collector pushed by the server
+type RemoteSystemd struct {
+	Enabled *bool     `yaml:"enabled,omitempty"`
+	Units   *[]string `yaml:"units,omitempty"`
+}
+
+// ParseRemote parses settings pushed by the server, only the keys of `Remote` are allowed
+func ParseRemote(content []byte) (Remote, error) {
+	remote := Remote{}
+
+	err := yaml.UnmarshalStrict(content, &remote)
+	if err != nil {
+		return remote, errors.Wrap(err, "cannot parse agent configuration")
+	}
+
+	if remote.PollingRate < 0 {
+		return remote, errors.New("the polling rate must be positive")
+	}
+	for name, collector := range remote.Collectors {
+		if collector.Interval < 0 || collector.Timeout < 0 {
+			return remote, errors.Errorf("the interval and the timeout of the collector '%v' must be positive", name)
+		}
+	}
+
+	return remote, nil
+}
+
+// MergeRemote merges settings pushed by the server (i.e. the settings of a group,
+// then the settings of a server), the last documents override the first ones
+func MergeRemote(documents ...[]byte) ([]byte, error) {
+	merged := Remote{}
+
+	for _, document := range documents {
+		remote, err := ParseRemote(document)
+		if err != nil {
+			return nil, err
+		}
+		merged = merged.merge(remote)
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// merge returns the settings overridden by the keys set in `other`
+func (r Remote) merge(other Remote) Remote {
+	if other.PollingRate > 0 {
+		r.PollingRate = other.PollingRate
+	}
+
+	if len(other.Collectors) > 0 {
+		collectors := map[string]RemoteCollector{}
+		for name, collector := range r.Collectors {
+			collectors[name] = collector
+		}
+		for name, collector := range other.Collectors {
+			merged := collectors[name]
+			if collector.Enabled != nil {
+				merged.Enabled = collector.Enabled
+			}
+			if collector.Interval > 0 {
+				merged.Interval = collector.Interval
+			}
+			if collector.Timeout > 0 {
+				merged.Timeout = collector.Timeout
+			}
+			collectors[name] = merged
+		}
+		r.Collectors = collectors
+	}
+
+	if other.Network != nil {
+		network := RemoteNetwork{}
+		if r.Network != nil {
+			network = *r.Network
+		}
+		if other.Network.Include != nil {
+			network.Include = other.Network.Include
+		}
+		if other.Network.Exclude != nil {
+			network.Exclude = other.Network.Exclude
+		}
+		r.Network = &network
+	}
+
+	if other.Systemd != nil {
+		systemd := RemoteSystemd{}
+		if r.Systemd != nil {
+			systemd = *r.Systemd
+		}
+		if other.Systemd.Enabled != nil {
+			systemd.Enabled = other.Systemd.Enabled
+		}
+		if other.Systemd.Units != nil {
+			systemd.Units = other.Systemd.Units
+		}
+		r.Systemd = &systemd
+	}
+
+	if other.TopProcesses != nil {
+		r.TopProcesses = other.TopProcesses
+	}
+	if other.Processes != nil {
+		r.Processes = other.Processes
+	}
+	if other.Cgroups != nil {
+		r.Cgroups = other.Cgroups
+	}
+
+	return r
+}
+
+// Apply applies settings pushed by the server on the configuration
+func Apply(content []byte, config Config) (Config, error) {
+	remote, err := ParseRemote(content)
+	if err != nil {
+		return config, err
+	}
+
+	if remote.PollingRate > 0 {
+		config.PollingRate = remote.PollingRate
+	}
+
+	if len(remote.Collectors) > 0 {
+		collectors := map[string]Collector{}
+		for name, collector := range config.Collectors {
+			collectors[name] = collector
+		}
+		for name, collector := range remote.Collectors {
+			applied := collectors[name]
+			if collector.Enabled != nil {
+				applied.Enabled = collector.Enabled
+			}
+			if collector.Interval > 0 {
+				applied.Interval = collector.Interval
+			}
+			if collector.Timeout > 0 {
+				applied.Timeout = collector.Timeout
+			}
+			collectors[name] = applied
+		}
+		config.Collectors = collectors
+	}
+
+	if remote.Network != nil {
+		if remote.Network.Include != nil {
+			config.Network.Include = *remote.Network.Include
+		}
+		if remote.Network.Exclude != nil {
+			config.Network.Exclude = *remote.Network.Exclude
+		}
+	}
+
+	if remote.Systemd != nil {
+		if remote.Systemd.Enabled != nil {
+			config.Systemd.Enabled = *remote.Systemd.Enabled
+		}
+		if remote.Systemd.Units != nil {
+			config.Systemd.Units = *remote.Systemd.Units
+		}
+	}
+
+	if remote.TopProcesses != nil {
+		config.TopProcesses = *remote.TopProcesses
+	}
+	if remote.Processes != nil {
+		config.Processes = *remote.Processes
+	}
+	if remote.Cgroups != nil {
+		config.Cgroups = *remote.Cgroups
+	}
+
+	return config, nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeRemote(t *testing.T) {
+	tests := []struct {
+		name      string
+		documents []string
+		expected  string
+	}{
+		{
+			name:      "collector settings are merged key by key",
+			documents: []string{"collectors: {disk: {interval: 30s}}", "collectors: {disk: {enabled: false}}"},
+			expected:  "collectors:\n  disk:\n    enabled: false\n    interval: 30s\n",
+		},
+		{
+			name:      "unset keys of a section are not emitted",
+			documents: []string{"systemd: {enabled: true}"},
+			expected:  "systemd:\n  enabled: true\n",
+		},
+		{
+			name:      "sections are merged key by key",
+			documents: []string{"network: {exclude: [lo]}", "network: {include: [eth0]}"},
+			expected:  "network:\n  include:\n  - eth0\n  exclude:\n  - lo\n",
+		},
+		{
+			name:      "lists are replaced",
+			documents: []string{"processes: ['a:name=a']", "processes: []"},
+			expected:  "processes: []\n",
+		},
+		{
+			name:      "the last documents override the first ones",
+			documents: []string{"polling_rate: 10s\ntop_processes: 3", "top_processes: 0", ""},
+			expected:  "polling_rate: 10s\ntop_processes: 0\n",
+		},
+		{
+			name:      "no settings",
+			documents: []string{"", ""},
+			expected:  "{}\n",
+		},
+	}
+
+	for _, test := range tests {
+		documents := [][]byte{}
+		for _, document := range test.documents {
+			documents = append(documents, []byte(document))
+		}
+
+		merged, err := MergeRemote(documents...)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if string(merged) != test.expected {
+			t.Errorf("%v: got\n%v\nexpected\n%v", test.name, string(merged), test.expected)
+		}
+	}
+}
+
+func TestMergeRemoteRejectsUnknownKeys(t *testing.T) {
+	if _, err := MergeRemote([]byte("jwt: token")); err == nil {
+		t.Error("keys which cannot be pushed must be rejected")
+	}
+	if _, err := MergeRemote([]byte("collectors: {disk: {interval: -1s}}")); err == nil {
+		t.Error("negative intervals must be rejected")
+	}
+}
+
+func TestApply(t *testing.T) {
+	enabled := true
+	local := Config{
+		PollingRate: time.Duration(5) * time.Second,
+		Collectors: map[string]Collector{
+			"disk": {Enabled: &enabled, Timeout: time.Duration(20) * time.Second},
+		},
+		Network: Network{Include: []string{}, Exclude: []string{"lo"}},
+		Systemd: Systemd{Units: []string{"nginx.service"}},
+		Cgroups: []string{"system.slice/*"},
+	}
+
+	applied, err := Apply([]byte("collectors: {disk: {interval: 1m}}\nnetwork: {include: [eth0]}\nsystemd: {enabled: true}\n"), local)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	disk := applied.Collectors["disk"]
+	if disk.Enabled == nil || !*disk.Enabled || disk.Interval != time.Minute || disk.Timeout != time.Duration(20)*time.Second {
+		t.Errorf("the local settings of the collector are lost: %+v", disk)
+	}
+	if !reflect.DeepEqual(applied.Network, Network{Include: []string{"eth0"}, Exclude: []string{"lo"}}) {
+		t.Errorf("the local network exclude is lost: %+v", applied.Network)
+	}
+	if !reflect.DeepEqual(applied.Systemd, Systemd{Enabled: true, Units: []string{"nginx.service"}}) {
+		t.Errorf("the local systemd units are lost: %+v", applied.Systemd)
+	}
+	if applied.PollingRate != local.PollingRate || !reflect.DeepEqual(applied.Cgroups, local.Cgroups) {
+		t.Errorf("unset keys must keep the local settings: %+v", applied)
+	}
+	if local.Collectors["disk"].Interval != 0 {
+		t.Error("the local configuration must not be modified")
+	}
+}
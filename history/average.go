@@ -0,0 +1,31 @@
+package history
+
+import "math"
+
+// averageAggregator computes the average of a metric storing a float (i.e. cpu.usage),
+// rounded to 2 decimals
+type averageAggregator struct {
+	AggregatedValue float64
+	Count           float64
+}
+
+func (agg *averageAggregator) AddValue(value interface{}) {
+	if v, ok := value.(float64); ok {
+		agg.AggregatedValue += v
+		agg.Count++
+	}
+}
+
+func (agg *averageAggregator) GetAverageValue() interface{} {
+	if agg.Count == 0 {
+		return nil
+	}
+
+	avg := agg.AggregatedValue / agg.Count
+
+	// reset
+	agg.AggregatedValue = 0
+	agg.Count = 0
+
+	return math.Round(avg/0.01) * 0.01
+}
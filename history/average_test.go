@@ -0,0 +1,27 @@
+package history
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAverageAggregator(t *testing.T) {
+	agg := new(averageAggregator)
+
+	if value := agg.GetAverageValue(); value != nil {
+		t.Errorf("got %v without values, expected nil", value)
+	}
+
+	for _, value := range []interface{}{10.0, 20.0, "ignored", 40.004} {
+		agg.AddValue(value)
+	}
+	if value, _ := agg.GetAverageValue().(float64); math.Abs(value-23.33) > 1e-9 {
+		t.Errorf("got %v, expected 23.33", value)
+	}
+
+	// values are reset once aggregated
+	agg.AddValue(5.0)
+	if value, _ := agg.GetAverageValue().(float64); math.Abs(value-5) > 1e-9 {
+		t.Errorf("got %v after reset, expected 5", value)
+	}
+}
@@ -0,0 +1,78 @@
+package history
+
+import (
+	"time"
+)
+
+const (
+	// minForecastHistory is the minimal duration of history needed to forecast
+	minForecastHistory = time.Duration(15) * time.Minute
+	// minForecastPoints is the minimal count of points of a partition needed to forecast
+	minForecastPoints = 10
+	// maxForecast is the longest forecast, partitions which are full later are not filling up
+	maxForecast = time.Duration(365*24) * time.Hour
+)
+
+// DiskFullForecast returns the estimated duration before each partition is full,
+// based on the 'disk.free' history. Partitions whose free space is not
+// decreasing, or without enough history, are omitted.
+func DiskFullForecast(history map[string][]Data) map[string]time.Duration {
+	forecast := map[string]time.Duration{}
+
+	points := history["disk.free"]
+	if len(points) == 0 {
+		return forecast
+	}
+
+	// partitions known in the last point
+	partitions, ok := points[len(points)-1].Value.(map[string]interface{})
+	if !ok {
+		return forecast
+	}
+
+	for mountpoint := range partitions {
+		read := EntryValue(mountpoint)
+		if !hasForecastHistory(points, read) {
+			continue
+		}
+
+		trend, ok := NewTrend(points, read)
+		if !ok || trend.Slope >= 0 {
+			continue
+		}
+
+		// time needed to reach 0 from the last estimated value, minus the time elapsed since.
+		// It is bounded before the conversion, a slow decrease would overflow the duration.
+		seconds := trend.Value / -trend.Slope
+		if seconds > maxForecast.Seconds() {
+			continue
+		}
+		remaining := time.Duration(seconds*float64(time.Second)) - time.Now().Sub(trend.Date)
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		forecast[mountpoint] = remaining
+	}
+
+	return forecast
+}
+
+// hasForecastHistory returns true if the points read for a partition
+// are numerous enough and cover the minimal duration
+func hasForecastHistory(points []Data, read ValueReader) bool {
+	count := 0
+	var first, last time.Time
+	for _, p := range points {
+		if _, ok := read(p.Value); !ok {
+			continue
+		}
+		if count == 0 {
+			first = p.Date
+		}
+		last = p.Date
+		count++
+	}
+
+	return count >= minForecastPoints && last.Sub(first) >= minForecastHistory
+}
@@ -0,0 +1,64 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+// freePoints returns `count` points of the free space of `/`, one per `step`
+// and ending now, computed by `value` from the index of the point
+func freePoints(count int, step time.Duration, value func(i int) float64) map[string][]Data {
+	points := []Data{}
+	start := time.Now().Add(-time.Duration(count-1) * step)
+	for i := 0; i < count; i++ {
+		points = append(points, Data{
+			Date:  start.Add(time.Duration(i) * step),
+			Value: map[string]interface{}{"/": value(i)},
+		})
+	}
+	return map[string][]Data{"disk.free": points}
+}
+
+func TestDiskFullForecast(t *testing.T) {
+	tests := []struct {
+		name    string
+		history map[string][]Data
+		// expected is the expected forecast, the partition must be omitted if 0
+		expected time.Duration
+	}{
+		{
+			name:     "steady decrease",
+			history:  freePoints(31, time.Minute, func(i int) float64 { return 100 - float64(i) }),
+			expected: time.Duration(70) * time.Minute,
+		},
+		{
+			name:    "increase",
+			history: freePoints(31, time.Minute, func(i int) float64 { return 100 + float64(i) }),
+		},
+		{
+			name:    "decrease too slow to be represented",
+			history: freePoints(31, time.Minute, func(i int) float64 { return 1e6 - float64(i)*1e-9 }),
+		},
+		{
+			name:    "not enough points",
+			history: freePoints(5, time.Duration(10)*time.Minute, func(i int) float64 { return 100 - float64(i) }),
+		},
+		{
+			name:    "history too short",
+			history: freePoints(20, time.Duration(30)*time.Second, func(i int) float64 { return 100 - float64(i) }),
+		},
+	}
+
+	for _, test := range tests {
+		remaining, ok := DiskFullForecast(test.history)["/"]
+		if test.expected == 0 {
+			if ok {
+				t.Errorf("%v: got forecast %v, expected no forecast", test.name, remaining)
+			}
+			continue
+		}
+		if !ok || remaining < test.expected-time.Minute || remaining > test.expected+time.Minute {
+			t.Errorf("%v: got forecast %v (%v), expected %v", test.name, remaining, ok, test.expected)
+		}
+	}
+}
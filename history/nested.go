@@ -0,0 +1,45 @@
+package history
+
+import "math"
+
+// nestedAggregator computes the average of a field for each entry of a metric
+// storing values by name (i.e. the free space of each partition)
+type nestedAggregator struct {
+	Field            string
+	AggregatedValues map[string]float64
+	Counts           map[string]float64
+}
+
+func newNestedAggregator(field string) *nestedAggregator {
+	return &nestedAggregator{
+		Field:            field,
+		AggregatedValues: map[string]float64{},
+		Counts:           map[string]float64{},
+	}
+}
+
+func (agg *nestedAggregator) AddValue(value interface{}) {
+	if entries, ok := value.(map[string]interface{}); ok {
+		for name, raw := range entries {
+			if fields, ok := raw.(map[string]interface{}); ok {
+				if v, ok := fields[agg.Field].(float64); ok {
+					agg.AggregatedValues[name] += v
+					agg.Counts[name]++
+				}
+			}
+		}
+	}
+}
+
+func (agg *nestedAggregator) GetAverageValue() interface{} {
+	avg := map[string]interface{}{}
+	for name, value := range agg.AggregatedValues {
+		avg[name] = math.Round((value/agg.Counts[name])/0.01) * 0.01
+	}
+
+	// reset
+	agg.AggregatedValues = map[string]float64{}
+	agg.Counts = map[string]float64{}
+
+	return avg
+}
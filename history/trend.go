@@ -0,0 +1,109 @@
+package history
+
+import (
+	"math"
+	"time"
+)
+
+// Trend is the result of a linear regression over history points
+type Trend struct {
+	// Slope is the variation of the value per second
+	Slope float64
+	// Value is the value estimated at `Date`, the date of the last point
+	Value float64
+	Date  time.Time
+	// Fit is the coefficient of determination (1 means a perfectly steady variation)
+	Fit float64
+}
+
+// ValueReader extracts a float value from the value of a point
+type ValueReader func(value interface{}) (float64, bool)
+
+// FloatValue is a ValueReader for points storing a float
+func FloatValue(value interface{}) (float64, bool) {
+	f, ok := value.(float64)
+	return f, ok
+}
+
+// EntryValue returns a ValueReader for points storing values by name
+// (i.e. by partition or by device)
+func EntryValue(name string) ValueReader {
+	return func(value interface{}) (float64, bool) {
+		if entries, ok := value.(map[string]interface{}); ok {
+			return FloatValue(entries[name])
+		}
+		return 0, false
+	}
+}
+
+// Since returns the points whose date is after `now - d`
+func Since(points []Data, d time.Duration) []Data {
+	from := time.Now().Add(-d)
+	for i, p := range points {
+		if p.Date.After(from) {
+			return points[i:]
+		}
+	}
+	return nil
+}
+
+// NewTrend computes a linear regression over the points.
+// It returns false if there is not enough points to compute a trend.
+func NewTrend(points []Data, read ValueReader) (*Trend, bool) {
+
+	xs := []float64{}
+	ys := []float64{}
+	var last time.Time
+	for _, p := range points {
+		if y, ok := read(p.Value); ok && !math.IsNaN(y) {
+			xs = append(xs, float64(p.Date.Unix()))
+			ys = append(ys, y)
+			last = p.Date
+		}
+	}
+
+	n := float64(len(xs))
+	if n < 3 {
+		return nil, false
+	}
+
+	// x is the number of seconds relative to the last point
+	var sumX, sumY float64
+	for i := range xs {
+		xs[i] -= float64(last.Unix())
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX := sumX / n
+	meanY := sumY / n
+
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx := xs[i] - meanX
+		dy := ys[i] - meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return nil, false
+	}
+
+	slope := sxy / sxx
+	fit := 1.0
+	if syy > 0 {
+		fit = (sxy * sxy) / (sxx * syy)
+	}
+
+	return &Trend{
+		Slope: slope,
+		Value: meanY - slope*meanX,
+		Date:  last,
+		Fit:   fit,
+	}, true
+}
+
+// PerHour returns the variation of the value per hour
+func (t *Trend) PerHour() float64 {
+	return t.Slope * time.Hour.Seconds()
+}
@@ -1,6 +1,7 @@
 package history
 
 import (
+	"sync"
 	"time"
 	"webup/syshealth"
 )
@@ -10,6 +11,9 @@ type serverID string
 type watcher struct {
 	aggregatorsByServer map[serverID]serverAggregator
 	fetcher             DataFetcher
+	// lastTick is the date of the last aggregation, older metrics are historical
+	lastTick time.Time
+	mutex    sync.RWMutex
 }
 
 type DataFetcher func(serverId string) map[string][]Data
@@ -22,17 +26,42 @@ type Data struct {
 type serverAggregator struct {
 	Aggregators map[string]aggregator
 	Data        map[string][]Data
+	// Replay contains the aggregators of historical metrics, by date of the point to fill
+	Replay map[time.Time]map[string]aggregator
+}
+
+// sources associates each history key with the metric key used to compute it
+var sources = map[string]string{
+	"cpu.usage":           "cpu.usage",
+	"memory.used_percent": "memory.used_percent",
+	"disk.free":           "disk.usage",
+	"disk.util":           "disk.io",
+	"memory.swap_in":      "memory.swap_in",
+	"memory.swap_out":     "memory.swap_out",
+}
+
+func newAggregators() map[string]aggregator {
+	return map[string]aggregator{
+		"cpu.usage":           new(averageAggregator),
+		"memory.used_percent": new(averageAggregator),
+		"disk.free":           newNestedAggregator("free"),
+		"disk.util":           newNestedAggregator("util"),
+		"memory.swap_in":      new(averageAggregator),
+		"memory.swap_out":     new(averageAggregator),
+	}
 }
 
 func newServerAggregator() serverAggregator {
 	s := serverAggregator{}
-	s.Aggregators = map[string]aggregator{
-		"cpu.usage":           new(cpuUsageAggregator),
-		"memory.used_percent": new(memoryUsageAggregator),
-	}
+	s.Aggregators = newAggregators()
+	s.Replay = map[time.Time]map[string]aggregator{}
 	s.Data = map[string][]Data{
 		"cpu.usage":           []Data{},
 		"memory.used_percent": []Data{},
+		"disk.free":           []Data{},
+		"disk.util":           []Data{},
+		"memory.swap_in":      []Data{},
+		"memory.swap_out":     []Data{},
 	}
 	return s
 }
@@ -61,7 +90,10 @@ func NewWatcher() (syshealth.Watcher, DataFetcher) {
 		for {
 			select {
 			case t := <-ticker:
+				w.mutex.Lock()
 				for server, sg := range w.aggregatorsByServer {
+					sg.replay()
+
 					for k, agg := range sg.Aggregators {
 						// add data for the aggregated value on the server
 						w.aggregatorsByServer[server].Data[k] = append(w.aggregatorsByServer[server].Data[k], Data{
@@ -75,6 +107,8 @@ func NewWatcher() (syshealth.Watcher, DataFetcher) {
 						}
 					}
 				}
+				w.lastTick = t
+				w.mutex.Unlock()
 
 				// fmt.Printf("%+v\n\n", w.aggregatorsByServer)
 			}
@@ -84,9 +118,17 @@ func NewWatcher() (syshealth.Watcher, DataFetcher) {
 	return &w, w.fetcher
 }
 
+// GetServerHistory returns a copy of the history of the server
 func (w *watcher) GetServerHistory(id string) map[string][]Data {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
 	if data, ok := w.aggregatorsByServer[serverID(id)]; ok {
-		return data.Data
+		history := map[string][]Data{}
+		for k, points := range data.Data {
+			history[k] = append([]Data{}, points...)
+		}
+		return history
 	}
 	return nil
 }
@@ -98,16 +140,70 @@ func (w *watcher) GetKey() syshealth.WatcherKey {
 func (w *watcher) Watch(data syshealth.WatcherData) {
 	id := serverID(data.Server.ID)
 
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
 	// init server aggregator if needed
 	if _, ok := w.aggregatorsByServer[id]; !ok {
 		w.aggregatorsByServer[id] = newServerAggregator()
 	}
 
-	historyMetrics := []string{"cpu.usage", "memory.used_percent"}
+	aggregators := w.aggregatorsByServer[id].Aggregators
+
+	// historical metrics (i.e. replayed by the agent after an outage) fill
+	// the point of the minute during which they were collected
+	if !data.Date.IsZero() && data.Date.Before(w.lastTick) {
+		date, ok := w.aggregatorsByServer[id].pointDate(data.Date)
+		if !ok {
+			return
+		}
+		if _, ok := w.aggregatorsByServer[id].Replay[date]; !ok {
+			w.aggregatorsByServer[id].Replay[date] = newAggregators()
+		}
+		aggregators = w.aggregatorsByServer[id].Replay[date]
+	}
 
-	for _, metric := range historyMetrics {
+	for historyKey, metric := range sources {
 		if val, ok := data.Metrics[metric]; ok {
-			w.aggregatorsByServer[id].Aggregators[metric].AddValue(val)
+			aggregators[historyKey].AddValue(val)
 		}
 	}
 }
+
+// pointDate returns the date of the point aggregating metrics collected at the given date
+func (s serverAggregator) pointDate(date time.Time) (time.Time, bool) {
+	// all the history keys share the same dates
+	for _, p := range s.Data["cpu.usage"] {
+		if p.Date.After(date) {
+			// each point aggregates the metrics of the previous minute
+			return p.Date, p.Date.Sub(date) <= time.Minute
+		}
+	}
+	return time.Time{}, false
+}
+
+// replay fills the points without value with the aggregated historical metrics
+func (s serverAggregator) replay() {
+	for date, aggregators := range s.Replay {
+		for k, agg := range aggregators {
+			value := agg.GetAverageValue()
+			if isEmpty(value) {
+				continue
+			}
+			for i, p := range s.Data[k] {
+				// metrics received live are kept
+				if p.Date.Equal(date) && isEmpty(p.Value) {
+					s.Data[k][i].Value = value
+				}
+			}
+		}
+		delete(s.Replay, date)
+	}
+}
+
+func isEmpty(value interface{}) bool {
+	if entries, ok := value.(map[string]interface{}); ok {
+		return len(entries) == 0
+	}
+	return value == nil
+}
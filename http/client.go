@@ -2,57 +2,211 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"webup/syshealth"
-	"webup/syshealth/metrics"
 
 	"github.com/pkg/errors"
 )
 
-// SendData is responsible to fetch server metrics and execute
-// a HTTP request to send collected data
-func SendData(serverURL string, jwt string) error {
+const (
+	connectTimeout = time.Duration(10) * time.Second
+	requestTimeout = time.Duration(30) * time.Second
 
-	data := syshealth.Data{}
+	// delays between attempts after a failure, doubled at each failure
+	minBackoff = time.Duration(1) * time.Second
+	maxBackoff = time.Duration(5) * time.Minute
+)
 
-	// fetch data
-	cpu, err := metrics.GetCPU()
-	if err != nil {
-		return errors.Wrap(err, "cannot get cpu metrics data")
+// ResponseError is returned when the API responds with an error status
+type ResponseError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by the server before the next attempt, if any
+	RetryAfter time.Duration
+}
+
+func (err *ResponseError) Error() string {
+	return fmt.Sprintf("API responded with error (status %d): %v", err.StatusCode, err.Message)
+}
+
+// IsRetryable returns true if sending data again may succeed
+// (i.e. the server is unreachable or temporarily unavailable).
+// Other errors (i.e. data which cannot be encoded) would fail again.
+func IsRetryable(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case *ResponseError:
+		return cause.StatusCode >= 500 || cause.StatusCode == http.StatusTooManyRequests
+	case *url.Error:
+		return isNetworkError(cause.Err)
+	default:
+		return isNetworkError(cause)
 	}
-	for k, v := range cpu {
-		data[k] = v
+}
+
+// isNetworkError returns true if the error is a network error or a timeout,
+// including a connection closed by the server
+func isNetworkError(err error) bool {
+	if _, ok := err.(net.Error); ok {
+		return true
 	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
 
-	memory, err := metrics.GetMemory()
-	if err != nil {
-		return errors.Wrap(err, "cannot get memory metrics data")
+// Client sends metrics to the server, reusing connections.
+// After a failure, the next attempt is delayed with an exponential backoff.
+type Client struct {
+	settings clientSettings
+
+	failures    int
+	nextAttempt time.Time
+	mutex       sync.Mutex
+}
+
+// clientSettings are the settings of the requests, which may be changed
+// without losing the backoff state
+type clientSettings struct {
+	serverURL string
+	jwt       string
+	compress  bool
+	client    *http.Client
+}
+
+// NewClient returns a client sending metrics to the server. The body
+// of the requests is compressed with gzip if `compress` is true.
+func NewClient(serverURL string, jwt string, compress bool, tlsConfig *tls.Config) *Client {
+	c := &Client{}
+	c.Configure(serverURL, jwt, compress, tlsConfig)
+	return c
+}
+
+// Configure changes the settings of the client (i.e. when the configuration of
+// the agent is reloaded), the backoff state is kept. Connections opened
+// with the previous settings are closed once idle.
+func (c *Client) Configure(serverURL string, jwt string, compress bool, tlsConfig *tls.Config) {
+	settings := clientSettings{
+		serverURL: serverURL,
+		jwt:       jwt,
+		compress:  compress,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				DialContext: (&net.Dialer{
+					Timeout:   connectTimeout,
+					KeepAlive: time.Duration(30) * time.Second,
+				}).DialContext,
+				TLSClientConfig:       tlsConfig,
+				TLSHandshakeTimeout:   connectTimeout,
+				ResponseHeaderTimeout: requestTimeout,
+				IdleConnTimeout:       time.Duration(90) * time.Second,
+				MaxIdleConnsPerHost:   1,
+			},
+			Timeout: requestTimeout,
+		},
 	}
-	for k, v := range memory {
-		data[k] = v
+
+	c.mutex.Lock()
+	previous := c.settings.client
+	c.settings = settings
+	c.mutex.Unlock()
+
+	if previous != nil {
+		previous.CloseIdleConnections()
 	}
+}
 
-	disk, err := metrics.GetDisk()
-	if err != nil {
-		return errors.Wrap(err, "cannot get disk metrics data")
+// current returns the settings of the next request
+func (c *Client) current() clientSettings {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.settings
+}
+
+// NextAttempt returns the date before which no data should be sent,
+// because of previous failures
+func (c *Client) NextAttempt() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.nextAttempt
+}
+
+// SendData executes a HTTP request to send collected data. Several metrics are sent as an array.
+func (c *Client) SendData(bags []syshealth.MetricBag) error {
+	err := c.send(bags)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !IsRetryable(err) {
+		c.failures = 0
+		c.nextAttempt = time.Time{}
+		return err
 	}
-	for k, v := range disk {
-		data[k] = v
+
+	c.failures++
+	delay := backoff(c.failures)
+	if respErr, ok := errors.Cause(err).(*ResponseError); ok && respErr.RetryAfter > 0 {
+		delay = respErr.RetryAfter
 	}
+	c.nextAttempt = time.Now().Add(delay)
 
-	jsonData := syshealth.MetricBag{Metrics: data}
+	return errors.Wrapf(err, "next attempt in %v", delay)
+}
+
+func (c *Client) send(bags []syshealth.MetricBag) error {
+	settings := c.current()
+
+	// the date of the request allows the server to detect the clock skew
+	sentAt := time.Now()
+	for i := range bags {
+		bags[i].SentAt = sentAt
+	}
+
+	// data (a single bag is sent as is, for servers not supporting arrays)
+	var payload interface{} = bags
+	if len(bags) == 1 {
+		payload = bags[0]
+	}
 
-	// data
 	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(jsonData)
+	if settings.compress {
+		writer := gzip.NewWriter(b)
+		if err := json.NewEncoder(writer).Encode(payload); err != nil {
+			return errors.Wrap(err, "unable to encode metrics")
+		}
+		if err := writer.Close(); err != nil {
+			return errors.Wrap(err, "unable to compress metrics")
+		}
+	} else {
+		if err := json.NewEncoder(b).Encode(payload); err != nil {
+			return errors.Wrap(err, "unable to encode metrics")
+		}
+	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", serverURL+"/api/metrics", b)
-	req.Header.Add("Authorization", "Bearer "+jwt)
+	req, err := http.NewRequest("POST", settings.serverURL+"/api/metrics", b)
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+	req.Header.Add("Authorization", "Bearer "+settings.jwt)
 	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	resp, err := client.Do(req)
+	if settings.compress {
+		req.Header.Add("Content-Encoding", "gzip")
+	}
+	resp, err := settings.client.Do(req)
 	if err != nil {
 		return errors.Wrap(err, "unable to send metrics")
 	}
@@ -60,8 +214,82 @@ func SendData(serverURL string, jwt string) error {
 
 	if resp.StatusCode >= 400 {
 		b, _ := ioutil.ReadAll(resp.Body)
-		return errors.Wrap(errors.New(string(b)), "API responded with error")
+		return &ResponseError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(b)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
+	// the body is read so that the connection is reused
+	io.Copy(ioutil.Discard, resp.Body)
+
 	return nil
 }
+
+// GetConfig executes a HTTP request to get the configuration pushed by the server.
+// The configuration is returned with its ETag, it is nil if it did not change since `etag`.
+func (c *Client) GetConfig(etag string) ([]byte, string, error) {
+	settings := c.current()
+
+	req, err := http.NewRequest("GET", settings.serverURL+"/api/agent/config", nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to create request")
+	}
+	req.Header.Add("Authorization", "Bearer "+settings.jwt)
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	resp, err := settings.client.Do(req)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to get configuration")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, etag, nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to read configuration")
+	}
+	if resp.StatusCode >= 400 {
+		return nil, "", &ResponseError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(b)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return b, resp.Header.Get("ETag"), nil
+}
+
+// backoff returns the delay before the next attempt, doubled at each failure,
+// with a random jitter so that agents do not retry at the same time
+func backoff(failures int) time.Duration {
+	delay := maxBackoff
+	if failures < 20 {
+		delay = minBackoff << uint(failures-1)
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter parses the value of the Retry-After header,
+// given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(time.Now()) {
+		return date.Sub(time.Now())
+	}
+	return 0
+}
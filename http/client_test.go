@@ -0,0 +1,100 @@
+package http
+
+import (
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+func TestConfigureKeepsBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", false, nil)
+	err := client.SendData([]syshealth.MetricBag{{Metrics: syshealth.Data{"cpu.usage": 1.0}}})
+	if !IsRetryable(err) {
+		t.Fatalf("got error %v, expected a retryable error", err)
+	}
+
+	nextAttempt := client.NextAttempt()
+	if nextAttempt.Sub(time.Now()) < time.Minute {
+		t.Fatalf("the next attempt must follow the Retry-After header, got %v", nextAttempt)
+	}
+
+	client.Configure(server.URL, "other token", true, nil)
+	if !client.NextAttempt().Equal(nextAttempt) {
+		t.Errorf("the backoff state must be kept after a reload, got %v instead of %v", client.NextAttempt(), nextAttempt)
+	}
+	if settings := client.current(); settings.jwt != "other token" || !settings.compress {
+		t.Errorf("the settings must be changed, got %+v", settings)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"no error", nil, false},
+		{"server error", &ResponseError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"too many requests", &ResponseError{StatusCode: http.StatusTooManyRequests}, true},
+		{"invalid request", &ResponseError{StatusCode: http.StatusBadRequest}, false},
+		{"unreachable server", errors.Wrap(&url.Error{Op: "Post", URL: "http://server", Err: refused}, "unable to send metrics"), true},
+		{"connection closed", &url.Error{Op: "Post", URL: "http://server", Err: io.EOF}, true},
+		{"unsupported scheme", &url.Error{Op: "Post", URL: "ftp://server", Err: errors.New("unsupported protocol scheme")}, false},
+		{"encoding failure", errors.Wrap(errors.New("json: unsupported value: NaN"), "unable to encode metrics"), false},
+	}
+
+	for _, test := range tests {
+		if retryable := IsRetryable(test.err); retryable != test.retryable {
+			t.Errorf("%v: got %v, expected %v", test.name, retryable, test.retryable)
+		}
+	}
+}
+
+func TestSendDataFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := server.URL
+	server.Close()
+
+	tests := []struct {
+		name      string
+		serverURL string
+		metrics   syshealth.Data
+		retryable bool
+	}{
+		{"unreachable server", closedURL, syshealth.Data{"cpu.usage": 1.0}, true},
+		{"encoding failure", closedURL, syshealth.Data{"cpu.usage": math.NaN()}, false},
+		{"invalid URL", "://server", syshealth.Data{"cpu.usage": 1.0}, false},
+	}
+
+	for _, test := range tests {
+		for _, compress := range []bool{false, true} {
+			client := NewClient(test.serverURL, "token", compress, nil)
+			err := client.SendData([]syshealth.MetricBag{{Metrics: test.metrics}})
+			if err == nil {
+				t.Fatalf("%v: expected an error", test.name)
+			}
+			if IsRetryable(err) != test.retryable {
+				t.Errorf("%v: got retryable %v for %v, expected %v", test.name, IsRetryable(err), err, test.retryable)
+			}
+			if backingOff := !client.NextAttempt().IsZero(); backingOff != test.retryable {
+				t.Errorf("%v: got backoff %v, expected %v", test.name, backingOff, test.retryable)
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// CgroupCollector collects the resources usage of cgroups (v2)
+type CgroupCollector struct {
+	root string
+	// cgroup paths, relative to the cgroup root (patterns are allowed, i.e. system.slice/*)
+	paths []string
+
+	// CPU counters of the previous poll by cgroup, used to compute rates
+	previousCPU  map[string]map[string]uint64
+	previousDate time.Time
+}
+
+// NewCgroupCollector returns a collector of the cgroups (v2) matching the paths
+func NewCgroupCollector(paths []string) *CgroupCollector {
+	return &CgroupCollector{
+		root:        cgroupRoot,
+		paths:       paths,
+		previousCPU: map[string]map[string]uint64{},
+	}
+}
+
+// Name returns the name of the collector
+func (c *CgroupCollector) Name() string {
+	return "cgroup"
+}
+
+// Collect returns the CPU, memory and pressure metrics of the configured cgroups
+func (c *CgroupCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	dirs, err := c.glob()
+	if err != nil {
+		return syshealth.Data{}, err
+	}
+
+	return c.collect(dirs)
+}
+
+// glob returns the directories of the cgroups matching the paths
+func (c *CgroupCollector) glob() ([]string, error) {
+	dirs := []string{}
+	for _, pattern := range c.paths {
+		matches, err := filepath.Glob(filepath.Join(c.root, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cgroup path '%v'", pattern)
+		}
+		dirs = append(dirs, matches...)
+	}
+	return dirs, nil
+}
+
+// collect reads the metrics of the cgroups, cgroups removed since the glob are ignored
+func (c *CgroupCollector) collect(dirs []string) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	now := time.Now()
+	seconds := now.Sub(c.previousDate).Seconds()
+
+	cgroups := map[string]interface{}{}
+	currentCPU := map[string]map[string]uint64{}
+
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		name, _ := filepath.Rel(c.root, dir)
+
+		cpu, err := readFlatKeyed(filepath.Join(dir, "cpu.stat"))
+		if os.IsNotExist(errors.Cause(err)) {
+			continue
+		}
+		if err != nil {
+			return data, errors.Wrapf(err, "cannot read cpu stats of cgroup '%v'", name)
+		}
+		currentCPU[name] = cpu
+
+		metrics := map[string]interface{}{
+			"nr_throttled": cpu["nr_throttled"],
+		}
+
+		// CPU usage and throttling are computed from the previous poll (times are in µs)
+		if previous, ok := c.previousCPU[name]; ok && seconds > 0 {
+			rates := counterSet{seconds: seconds * 1e6}
+			usage := rates.rate(previous["usage_usec"], cpu["usage_usec"]) * 100
+			throttled := rates.rate(previous["throttled_usec"], cpu["throttled_usec"]) * 100
+			if !rates.reset {
+				metrics["cpu_percent"] = usage
+				metrics["throttled_percent"] = throttled
+			}
+		}
+
+		readCgroupMemory(dir, metrics)
+
+		// pressure stall information (only available on recent kernels)
+		for _, resource := range []string{"cpu", "memory", "io"} {
+			if pressure, err := readPressure(filepath.Join(dir, resource+".pressure")); err == nil {
+				metrics["pressure_"+resource] = pressure
+			}
+		}
+
+		cgroups[name] = metrics
+	}
+
+	c.previousCPU = currentCPU
+	c.previousDate = now
+
+	data["cgroup.usage"] = cgroups
+
+	return data, nil
+}
+
+// readCgroupMemory adds the memory usage and limit of the cgroup to the metrics (in MB),
+// the percent is only computed when both are read
+func readCgroupMemory(dir string, metrics map[string]interface{}) {
+	current, currentErr := readCgroupValue(filepath.Join(dir, "memory.current"))
+	if currentErr == nil {
+		metrics["memory_current"] = float64(current) / 1024 / 1024
+	}
+	max, err := readCgroupValue(filepath.Join(dir, "memory.max"))
+	if err == nil && max > 0 {
+		metrics["memory_max"] = float64(max) / 1024 / 1024
+		if currentErr == nil {
+			metrics["memory_percent"] = float64(current) / float64(max) * 100
+		}
+	}
+}
+
+// readFlatKeyed reads a cgroup file made of `key value` lines
+func readFlatKeyed(path string) (map[string]uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]uint64{}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = value
+		}
+	}
+
+	return values, nil
+}
+
+// readCgroupValue reads a cgroup file containing a single value.
+// 'max' (no limit) is returned as 0.
+func readCgroupValue(path string) (uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
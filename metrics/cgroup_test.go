@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCgroupCollectorRemovedCgroup(t *testing.T) {
+	root, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"web.service", "job.service", "stopping.service"} {
+		dir := filepath.Join(root, "system.slice", name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		// the files of a cgroup being removed are already gone
+		if name != "stopping.service" {
+			if err := ioutil.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 1000\nnr_throttled 0\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	collector := NewCgroupCollector([]string{"system.slice/*"})
+	collector.root = root
+
+	dirs, err := collector.glob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 3 {
+		t.Fatalf("got %d cgroups, expected 3", len(dirs))
+	}
+
+	// the cgroup is removed between the glob and the reading of its files
+	if err := os.RemoveAll(filepath.Join(root, "system.slice", "job.service")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := collector.collect(dirs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cgroups := data["cgroup.usage"].(map[string]interface{})
+	if _, ok := cgroups[filepath.Join("system.slice", "web.service")]; !ok || len(cgroups) != 1 {
+		t.Errorf("got cgroups %v, expected only system.slice/web.service", cgroups)
+	}
+}
+
+func TestReadCgroupMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected map[string]interface{}
+	}{
+		{
+			name:  "usage and limit",
+			files: map[string]string{"memory.current": "268435456\n", "memory.max": "1073741824\n"},
+			expected: map[string]interface{}{
+				"memory_current": 256.0,
+				"memory_max":     1024.0,
+				"memory_percent": 25.0,
+			},
+		},
+		{
+			name:     "no limit",
+			files:    map[string]string{"memory.current": "268435456\n", "memory.max": "max\n"},
+			expected: map[string]interface{}{"memory_current": 256.0},
+		},
+		{
+			name:     "unreadable usage",
+			files:    map[string]string{"memory.current": "invalid\n", "memory.max": "1073741824\n"},
+			expected: map[string]interface{}{"memory_max": 1024.0},
+		},
+		{
+			name:     "missing usage",
+			files:    map[string]string{"memory.max": "1073741824\n"},
+			expected: map[string]interface{}{"memory_max": 1024.0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cgroup")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, content := range test.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			metrics := map[string]interface{}{}
+			readCgroupMemory(dir, metrics)
+			if !reflect.DeepEqual(metrics, test.expected) {
+				t.Errorf("got %v, expected %v", metrics, test.expected)
+			}
+		})
+	}
+}
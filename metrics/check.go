@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+// states of the checks, given by the exit code of Nagios plugins
+const (
+	CheckOK       = 0
+	CheckWarning  = 1
+	CheckCritical = 2
+	CheckUnknown  = 3
+)
+
+// CheckPrefix is the prefix of the keys of the check results (i.e. `checks.http`)
+const CheckPrefix = "checks."
+
+// the output of a check is truncated, only its summary is sent
+const maxCheckOutput = 1024
+
+// delay given to the processes of a check to release its output once it is killed
+const checkWaitDelay = time.Duration(1) * time.Second
+
+var checkStatuses = map[int]string{
+	CheckOK:       "OK",
+	CheckWarning:  "WARNING",
+	CheckCritical: "CRITICAL",
+	CheckUnknown:  "UNKNOWN",
+}
+
+// CheckCollector runs a command compatible with Nagios plugins
+type CheckCollector struct {
+	name    string
+	command []string
+}
+
+// NewCheckCollector returns a collector running the command (the executable then its arguments)
+func NewCheckCollector(name string, command []string) *CheckCollector {
+	return &CheckCollector{name: name, command: command}
+}
+
+// Name returns the name of the collector (i.e. `checks.http`)
+func (c *CheckCollector) Name() string {
+	return CheckPrefix + c.name
+}
+
+// Collect runs the command and returns its state, its output and its performance data.
+// A command which cannot be run, or does not end before the timeout, is in the unknown state.
+func (c *CheckCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	if len(c.command) == 0 {
+		return nil, errors.New("the command is required")
+	}
+
+	start := time.Now()
+	stdout, err := runCheckCommand(ctx, exec.Command(c.command[0], c.command[1:]...))
+	duration := time.Now().Sub(start)
+
+	state := CheckOK
+	output, perfdata := parseCheckOutput(stdout)
+
+	if err != nil {
+		state = CheckUnknown
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 && exitErr.ExitCode() <= CheckUnknown {
+			state = exitErr.ExitCode()
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			output = "check timed out"
+		} else if output == "" {
+			output = err.Error()
+		}
+	}
+
+	data := syshealth.Data{
+		c.Name(): map[string]interface{}{
+			"state":    state,
+			"status":   checkStatuses[state],
+			"output":   output,
+			"perfdata": perfdata,
+			"duration": duration.Seconds(),
+		},
+	}
+
+	return data, nil
+}
+
+// runCheckCommand runs the command and returns its output. When the context is done,
+// the command is killed with the processes it forked, so that they do not block
+// the collector by keeping the output open. Processes still holding the output
+// are not waited for longer than the wait delay.
+func runCheckCommand(ctx context.Context, cmd *exec.Cmd) (string, error) {
+	stdout := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return stdout.String(), err
+	case <-ctx.Done():
+		killProcessGroup(cmd.Process)
+	}
+
+	timer := time.NewTimer(checkWaitDelay)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return stdout.String(), err
+	case <-timer.C:
+		// the output is still written by the remaining processes
+		return "", ctx.Err()
+	}
+}
+
+// parseCheckOutput parses the output of a Nagios plugin, made of a summary
+// then of long text lines, with performance data after `|` characters
+// i.e. `DISK OK - free space: / 3326 MB (56%) | /=2643MB;5948;5958;0;5968`
+func parseCheckOutput(output string) (string, map[string]interface{}) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+
+	summary := lines[0]
+	perfdata := ""
+	if i := strings.Index(summary, "|"); i >= 0 {
+		perfdata = summary[i+1:]
+		summary = summary[:i]
+	}
+
+	// performance data of the long text starts after its first `|`, until the end of the output
+	for i, line := range lines[1:] {
+		if j := strings.Index(line, "|"); j >= 0 {
+			perfdata += " " + line[j+1:] + " " + strings.Join(lines[i+2:], " ")
+			break
+		}
+	}
+
+	summary = strings.TrimSpace(summary)
+	if len(summary) > maxCheckOutput {
+		summary = summary[:maxCheckOutput]
+	}
+
+	return summary, parsePerfdata(perfdata)
+}
+
+// parsePerfdata parses performance data, defined as `'label'=value[unit];[warn];[crit];[min];[max]`
+// and separated by spaces. Values which are not numbers (i.e. `U` for undetermined) are ignored.
+func parsePerfdata(perfdata string) map[string]interface{} {
+	metrics := map[string]interface{}{}
+
+	for _, field := range splitPerfdata(perfdata) {
+		i := strings.LastIndex(field, "=")
+		if i <= 0 {
+			continue
+		}
+		label := field[:i]
+		if strings.HasPrefix(label, "'") && strings.HasSuffix(label, "'") && len(label) > 1 {
+			label = strings.Replace(label[1:len(label)-1], "''", "'", -1)
+		}
+
+		values := strings.Split(field[i+1:], ";")
+
+		// the unit follows the value (i.e. `10ms`)
+		number := strings.TrimRightFunc(values[0], func(r rune) bool {
+			return !unicode.IsDigit(r) && r != '.'
+		})
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil {
+			continue
+		}
+
+		metric := map[string]interface{}{
+			"value": value,
+			"unit":  values[0][len(number):],
+		}
+		for j, name := range []string{"warn", "crit"} {
+			if len(values) > j+1 && values[j+1] != "" {
+				metric[name] = values[j+1]
+			}
+		}
+		for j, name := range []string{"min", "max"} {
+			if len(values) > j+3 {
+				if limit, err := strconv.ParseFloat(values[j+3], 64); err == nil {
+					metric[name] = limit
+				}
+			}
+		}
+
+		metrics[label] = metric
+	}
+
+	return metrics
+}
+
+// splitPerfdata splits performance data on spaces, except in quoted labels
+func splitPerfdata(perfdata string) []string {
+	fields := []string{}
+	current := ""
+	quoted := false
+
+	for _, r := range perfdata {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			current += string(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current != "" {
+				fields = append(fields, current)
+			}
+			current = ""
+		default:
+			current += string(r)
+		}
+	}
+	if current != "" {
+		fields = append(fields, current)
+	}
+
+	return fields
+}
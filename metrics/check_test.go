@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func runCheck(t *testing.T, timeout time.Duration, command ...string) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := NewCheckCollector("test", command).Collect(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return data["checks.test"].(map[string]interface{})
+}
+
+func TestCheckStates(t *testing.T) {
+	tests := []struct {
+		script string
+		state  int
+		output string
+	}{
+		{"echo 'OK - fine'; exit 0", CheckOK, "OK - fine"},
+		{"echo 'WARNING - slow'; exit 1", CheckWarning, "WARNING - slow"},
+		{"echo 'CRITICAL - down'; exit 2", CheckCritical, "CRITICAL - down"},
+		{"echo 'UNKNOWN - ?'; exit 3", CheckUnknown, "UNKNOWN - ?"},
+		{"exit 7", CheckUnknown, "exit status 7"},
+	}
+
+	for _, test := range tests {
+		result := runCheck(t, time.Duration(5)*time.Second, "sh", "-c", test.script)
+		if result["state"] != test.state || result["output"] != test.output {
+			t.Errorf("%v: got state %v and output %q, expected %v and %q", test.script, result["state"], result["output"], test.state, test.output)
+		}
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	start := time.Now()
+	result := runCheck(t, time.Duration(200)*time.Millisecond, "sh", "-c", "sleep 30")
+
+	if result["state"] != CheckUnknown || result["output"] != "check timed out" {
+		t.Errorf("got state %v and output %q, expected unknown state after timeout", result["state"], result["output"])
+	}
+	if elapsed := time.Now().Sub(start); elapsed > time.Duration(3)*time.Second {
+		t.Errorf("the check returned after %v", elapsed)
+	}
+}
+
+func TestCheckTimeoutWithForkedProcess(t *testing.T) {
+	// the grandchild keeps the output open after its parent is killed
+	start := time.Now()
+	result := runCheck(t, time.Duration(200)*time.Millisecond, "sh", "-c", "sleep 30 & wait")
+
+	if result["state"] != CheckUnknown {
+		t.Errorf("got state %v, expected unknown state after timeout", result["state"])
+	}
+	if elapsed := time.Now().Sub(start); elapsed > time.Duration(3)*time.Second {
+		t.Errorf("the check returned after %v", elapsed)
+	}
+}
+
+func TestParseCheckOutput(t *testing.T) {
+	output, perfdata := parseCheckOutput("DISK OK - free space | /=2643MB;5948;5958;0;5968 'my load'=0.5;1:2;@3 x=U\nlong text\nmore | time=1.5s\nsize=2%;;5\n")
+
+	if output != "DISK OK - free space" {
+		t.Errorf("got output %q", output)
+	}
+
+	expected := map[string]interface{}{
+		"/":       map[string]interface{}{"value": 2643.0, "unit": "MB", "warn": "5948", "crit": "5958", "min": 0.0, "max": 5968.0},
+		"my load": map[string]interface{}{"value": 0.5, "unit": "", "warn": "1:2", "crit": "@3"},
+		"time":    map[string]interface{}{"value": 1.5, "unit": "s"},
+		"size":    map[string]interface{}{"value": 2.0, "unit": "%", "crit": "5"},
+	}
+	if !reflect.DeepEqual(perfdata, expected) {
+		t.Errorf("got perfdata %v, expected %v", perfdata, expected)
+	}
+}
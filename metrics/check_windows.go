@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing, process groups are not available
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// killProcessGroup only kills the command, the processes it forked
+// are not waited for longer than the wait delay
+func killProcessGroup(process *os.Process) {
+	process.Kill()
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorsKey is the metric key containing the errors of failing collectors, by collector name
+const ErrorsKey = "collector.errors"
+
+// Collector gathers a set of metrics
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) (syshealth.Data, error)
+}
+
+// Schedule defines how often a collector runs, and how long it may take
+type Schedule struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// result is the outcome of the last run of a collector
+type result struct {
+	Data syshealth.Data
+	Err  error
+}
+
+// Registry contains the collectors known by the agent, whether they are enabled,
+// and the results of their last run
+type Registry struct {
+	collectors []Collector
+	enabled    map[string]bool
+	schedules  map[string]Schedule
+	// schedule of the collectors without a specific one
+	defaultSchedule Schedule
+	results         map[string]result
+	mutex           sync.RWMutex
+}
+
+// NewRegistry returns an empty registry, where collectors run with the default schedule
+func NewRegistry(defaultSchedule Schedule) *Registry {
+	return &Registry{
+		collectors:      []Collector{},
+		enabled:         map[string]bool{},
+		schedules:       map[string]Schedule{},
+		defaultSchedule: defaultSchedule,
+		results:         map[string]result{},
+	}
+}
+
+// Register adds a collector to the registry. A collector registered with
+// the name of another collector replaces it.
+func (r *Registry) Register(c Collector, enabled bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.collectors {
+		if existing.Name() == c.Name() {
+			r.collectors[i] = c
+			r.enabled[c.Name()] = enabled
+			return
+		}
+	}
+
+	r.collectors = append(r.collectors, c)
+	r.enabled[c.Name()] = enabled
+}
+
+// Enable enables or disables the collector
+func (r *Registry) Enable(name string, enabled bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.enabled[name]; !ok {
+		return errors.Errorf("unknown collector '%v'", name)
+	}
+	r.enabled[name] = enabled
+
+	return nil
+}
+
+// SetSchedule sets the interval and the timeout of the collector.
+// A zero value keeps the default interval or timeout.
+func (r *Registry) SetSchedule(name string, schedule Schedule) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.enabled[name]; !ok {
+		return errors.Errorf("unknown collector '%v'", name)
+	}
+	r.schedules[name] = schedule
+
+	return nil
+}
+
+// Enabled returns the enabled collectors, in the registration order
+func (r *Registry) Enabled() []Collector {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	collectors := []Collector{}
+	for _, c := range r.collectors {
+		if r.enabled[c.Name()] {
+			collectors = append(collectors, c)
+		}
+	}
+	return collectors
+}
+
+// schedule returns the schedule of the collector, completed with the default one
+func (r *Registry) schedule(name string) Schedule {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	schedule := r.schedules[name]
+	if schedule.Interval <= 0 {
+		schedule.Interval = r.defaultSchedule.Interval
+	}
+	if schedule.Timeout <= 0 {
+		schedule.Timeout = r.defaultSchedule.Timeout
+	}
+	// a run must end before the next one
+	if schedule.Timeout <= 0 || schedule.Timeout > schedule.Interval {
+		schedule.Timeout = schedule.Interval
+	}
+	return schedule
+}
+
+// Run starts the enabled collectors, each one running at its own interval,
+// until the context is canceled
+func (r *Registry) Run(ctx context.Context) {
+	for _, c := range r.Enabled() {
+		go r.run(ctx, c, r.schedule(c.Name()))
+	}
+}
+
+func (r *Registry) run(ctx context.Context, c Collector, schedule Schedule) {
+	ticker := time.NewTicker(schedule.Interval)
+	defer ticker.Stop()
+
+	for {
+		collectCtx, cancel := context.WithTimeout(ctx, schedule.Timeout)
+		metrics, err := c.Collect(collectCtx)
+		if err == nil && collectCtx.Err() == context.DeadlineExceeded {
+			err = errors.Errorf("timeout after %v", schedule.Timeout)
+		}
+		cancel()
+
+		r.mutex.Lock()
+		r.results[c.Name()] = result{Data: metrics, Err: err}
+		r.mutex.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Snapshot merges the last metrics of the enabled collectors.
+// The error of a failing collector is reported in the metrics under `ErrorsKey`
+// instead of dropping the metrics of the other collectors.
+func (r *Registry) Snapshot() syshealth.Data {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	data := syshealth.Data{}
+	failures := map[string]interface{}{}
+
+	for _, c := range r.collectors {
+		res, ok := r.results[c.Name()]
+		if !ok || !r.enabled[c.Name()] {
+			continue
+		}
+
+		// metrics gathered before an error are kept
+		for k, v := range res.Data {
+			data[k] = v
+		}
+		if res.Err != nil {
+			failures[c.Name()] = res.Err.Error()
+		}
+	}
+
+	if len(failures) > 0 {
+		data[ErrorsKey] = failures
+	}
+
+	return data
+}
@@ -0,0 +1,27 @@
+package metrics
+
+// counterSet computes the increase of cumulative counters between two polls.
+// A counter lower than at the previous poll was reset (i.e. after a reboot,
+// the re-initialization of an interface or the restart of a container): the
+// sample must be dropped, the current values are the new baseline.
+type counterSet struct {
+	seconds float64
+	reset   bool
+}
+
+// delta returns the increase of a counter, 0 if it was reset
+func (c *counterSet) delta(previous uint64, current uint64) uint64 {
+	if current < previous {
+		c.reset = true
+		return 0
+	}
+	return current - previous
+}
+
+// rate returns the increase of a counter per second, 0 if it was reset
+func (c *counterSet) rate(previous uint64, current uint64) float64 {
+	if c.seconds <= 0 {
+		return 0
+	}
+	return float64(c.delta(previous, current)) / c.seconds
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import "testing"
+
+func TestCountersRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous uint64
+		current  uint64
+		seconds  float64
+		rate     float64
+		reset    bool
+	}{
+		{"increase", 100, 300, 2, 100, false},
+		{"unchanged", 100, 100, 2, 0, false},
+		{"no elapsed time", 100, 300, 0, 0, false},
+		{"reset after a reboot", 5000000, 1200, 5, 0, true},
+		{"reset of a 32 bits counter", 4294967000, 10, 5, 0, true},
+		{"reset of a 64 bits counter", 18446744073709551000, 10, 5, 0, true},
+	}
+
+	for _, test := range tests {
+		rates := counterSet{seconds: test.seconds}
+		rate := rates.rate(test.previous, test.current)
+		if rate != test.rate || rates.reset != test.reset {
+			t.Errorf("%v: got rate %v and reset %v, expected %v and %v", test.name, rate, rates.reset, test.rate, test.reset)
+		}
+	}
+}
+
+func TestCountersResetIsKept(t *testing.T) {
+	deltas := counterSet{seconds: 1}
+
+	if delta := deltas.delta(10, 2); delta != 0 {
+		t.Errorf("delta of a reset counter: got %v, expected 0", delta)
+	}
+	if delta := deltas.delta(2, 10); delta != 8 {
+		t.Errorf("delta after a reset counter: got %v, expected 8", delta)
+	}
+	if !deltas.reset {
+		t.Error("the reset of a counter must be kept until the sample is dropped")
+	}
+}
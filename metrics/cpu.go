@@ -1,7 +1,7 @@
 package metrics
 
 import (
-	"time"
+	"context"
 	"webup/syshealth"
 
 	"github.com/pkg/errors"
@@ -17,30 +17,50 @@ const (
 	load15Key    = "cpu.load_15"
 )
 
-type name struct {
+// CPUCollector collects the CPU usage and load
+type CPUCollector struct {
+	// CPU times of the previous poll, used to compute the usage
+	previousTimes *cpu.TimesStat
 }
 
-func GetCPU() (syshealth.Data, error) {
+// NewCPUCollector returns a collector of CPU usage and load
+func NewCPUCollector() *CPUCollector {
+	return &CPUCollector{}
+}
+
+// Name returns the name of the collector
+func (c *CPUCollector) Name() string {
+	return "cpu"
+}
+
+// Collect returns the CPU usage and the load, divided by the number of cores
+func (c *CPUCollector) Collect(ctx context.Context) (syshealth.Data, error) {
 
 	data := syshealth.Data{}
 
 	// cpu count
-	cpuCount, err := cpu.Counts(false)
+	cpuCount, err := cpu.CountsWithContext(ctx, false)
 	if err != nil {
 		cpuCount = 1
 	}
 
 	data[coreCountKey] = cpuCount
 
-	// percent
-	percent, err := cpu.Percent(time.Second, false)
+	// percent, computed from the previous poll instead of waiting for a second
+	times, err := cpu.TimesWithContext(ctx, false)
 	if err != nil {
-		return data, errors.Wrap(err, "cannot get CPU percent")
+		return data, errors.Wrap(err, "cannot get CPU times")
 	}
-	data[usageKey] = percent[0]
+	if len(times) == 0 {
+		return data, errors.New("cannot get CPU times")
+	}
+	if c.previousTimes != nil {
+		data[usageKey] = cpuBusyPercent(*c.previousTimes, times[0])
+	}
+	c.previousTimes = &times[0]
 
 	// load
-	l, err := load.Avg()
+	l, err := load.AvgWithContext(ctx)
 	if err != nil {
 		return data, errors.Wrap(err, "cannot get CPU load")
 	}
@@ -55,3 +75,22 @@ func GetCPU() (syshealth.Data, error) {
 
 	return data, nil
 }
+
+// cpuBusyPercent computes the CPU usage between two polls, like `cpu.Percent`
+func cpuBusyPercent(previous cpu.TimesStat, current cpu.TimesStat) float64 {
+	previousTotal, previousBusy := cpuBusy(previous)
+	currentTotal, currentBusy := cpuBusy(current)
+
+	if currentBusy <= previousBusy {
+		return 0
+	}
+	if currentTotal <= previousTotal {
+		return 100
+	}
+	return (currentBusy - previousBusy) / (currentTotal - previousTotal) * 100
+}
+
+func cpuBusy(t cpu.TimesStat) (total float64, busy float64) {
+	busy = t.User + t.System + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal + t.Guest + t.GuestNice + t.Stolen
+	return busy + t.Idle, busy
+}
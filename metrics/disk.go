@@ -1,18 +1,33 @@
 package metrics
 
 import (
+	"context"
 	"webup/syshealth"
 
 	"github.com/pkg/errors"
 	"github.com/shirou/gopsutil/disk"
 )
 
-func GetDisk() (syshealth.Data, error) {
+// DiskCollector collects the usage of partitions
+type DiskCollector struct{}
+
+// NewDiskCollector returns a collector of partitions usage
+func NewDiskCollector() *DiskCollector {
+	return &DiskCollector{}
+}
+
+// Name returns the name of the collector
+func (c *DiskCollector) Name() string {
+	return "disk"
+}
+
+// Collect returns the space and inodes usage of each partition
+func (c *DiskCollector) Collect(ctx context.Context) (syshealth.Data, error) {
 
 	data := syshealth.Data{}
 
 	// disk
-	d, err := disk.Partitions(false)
+	d, err := disk.PartitionsWithContext(ctx, false)
 	if err != nil {
 		return data, errors.Wrap(err, "cannot get partitions")
 	}
@@ -20,7 +35,7 @@ func GetDisk() (syshealth.Data, error) {
 	partitions := map[string]interface{}{}
 
 	for _, info := range d {
-		u, err := disk.Usage(info.Mountpoint)
+		u, err := disk.UsageWithContext(ctx, info.Mountpoint)
 		if err != nil {
 			return data, errors.Wrap(err, "cannot get partitions")
 		}
@@ -29,9 +44,12 @@ func GetDisk() (syshealth.Data, error) {
 		free := ((float64(u.Free) / 1024) / 1024) / 1024
 
 		partitions[info.Mountpoint] = map[string]interface{}{
-			"total":   total,
-			"free":    free,
-			"percent": u.UsedPercent,
+			"total":          total,
+			"free":           free,
+			"percent":        u.UsedPercent,
+			"inodes_total":   float64(u.InodesTotal),
+			"inodes_free":    float64(u.InodesFree),
+			"inodes_percent": u.InodesUsedPercent,
 		}
 	}
 
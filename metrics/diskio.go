@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// devices ignored by I/O metrics
+var ignoredDevicePrefixes = []string{"loop", "ram"}
+
+// DiskIOCollector collects the I/O activity of devices
+type DiskIOCollector struct {
+	// counters of the previous poll, used to compute rates
+	previousCounters map[string]disk.IOCountersStat
+	previousDate     time.Time
+}
+
+// NewDiskIOCollector returns a collector of devices I/O activity
+func NewDiskIOCollector() *DiskIOCollector {
+	return &DiskIOCollector{
+		previousCounters: map[string]disk.IOCountersStat{},
+	}
+}
+
+// Name returns the name of the collector
+func (c *DiskIOCollector) Name() string {
+	return "diskio"
+}
+
+// Collect returns I/O metrics for each device, computed from
+// the counters of /proc/diskstats between two polls
+func (c *DiskIOCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot get disk I/O counters")
+	}
+
+	now := time.Now()
+	seconds := now.Sub(c.previousDate).Seconds()
+
+	devices := map[string]interface{}{}
+	current := map[string]disk.IOCountersStat{}
+
+	for name, counter := range counters {
+		if isDeviceIgnored(name) {
+			continue
+		}
+		current[name] = counter
+
+		// rates are computed from the previous poll
+		p, ok := c.previousCounters[name]
+		if !ok || seconds <= 0 {
+			continue
+		}
+
+		deltas := counterSet{seconds: seconds}
+
+		// average time (in ms) spent by each operation
+		operations := deltas.delta(p.ReadCount, counter.ReadCount) + deltas.delta(p.WriteCount, counter.WriteCount)
+		latency := 0.0
+		if operations > 0 {
+			latency = float64(deltas.delta(p.ReadTime, counter.ReadTime)+deltas.delta(p.WriteTime, counter.WriteTime)) / float64(operations)
+		}
+
+		// percent of time spent doing I/O (io time is in ms)
+		util := math.Min(float64(deltas.delta(p.IoTime, counter.IoTime))/(seconds*1000)*100, 100)
+
+		metrics := map[string]interface{}{
+			"read_bytes":  deltas.rate(p.ReadBytes, counter.ReadBytes),
+			"write_bytes": deltas.rate(p.WriteBytes, counter.WriteBytes),
+			"read_iops":   deltas.rate(p.ReadCount, counter.ReadCount),
+			"write_iops":  deltas.rate(p.WriteCount, counter.WriteCount),
+			"latency":     latency,
+			"util":        util,
+		}
+		if !deltas.reset {
+			devices[name] = metrics
+		}
+	}
+
+	c.previousCounters = current
+	c.previousDate = now
+
+	data["disk.io"] = devices
+
+	return data, nil
+}
+
+func isDeviceIgnored(name string) bool {
+	for _, prefix := range ignoredDevicePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
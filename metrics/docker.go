@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+const dockerTimeout = time.Duration(10) * time.Second
+
+// maximal count of containers whose stats are requested at the same time
+const dockerConcurrency = 8
+
+// DockerCollector collects the state and the resources usage of Docker containers
+type DockerCollector struct {
+	client *http.Client
+
+	// network counters of the previous poll by container id, used to compute rates
+	previousNetwork map[string]dockerNetworkCounters
+	mutex           sync.Mutex
+}
+
+type dockerNetworkCounters struct {
+	Date    time.Time
+	RxBytes uint64
+	TxBytes uint64
+}
+
+// NewDockerCollector returns a collector using the Docker API on the given unix socket
+func NewDockerCollector(socket string) *DockerCollector {
+	return &DockerCollector{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+			Timeout: dockerTimeout,
+		},
+		previousNetwork: map[string]dockerNetworkCounters{},
+	}
+}
+
+// Name returns the name of the collector
+func (c *DockerCollector) Name() string {
+	return "docker"
+}
+
+// docker API responses (only used fields)
+
+type dockerContainer struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+	Image string   `json:"Image"`
+	State string   `json:"State"`
+}
+
+type dockerContainerDetails struct {
+	RestartCount int `json:"RestartCount"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint64 `json:"online_cpus"`
+}
+
+type dockerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64 `json:"usage"`
+		Limit uint64 `json:"limit"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+}
+
+// Collect returns the state and the resources usage of each container
+func (c *DockerCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	list := []dockerContainer{}
+	err := c.get(ctx, "/containers/json?all=1", &list)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot list containers")
+	}
+
+	containers := map[string]interface{}{}
+	mutex := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	// stats of each container take some time to be computed by Docker,
+	// a few containers are requested at the same time
+	semaphore := make(chan struct{}, dockerConcurrency)
+	for _, container := range list {
+		wg.Add(1)
+		go func(container dockerContainer) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			metrics, err := c.getContainerMetrics(ctx, container)
+			if err != nil {
+				metrics = map[string]interface{}{
+					"state": container.State,
+					"error": err.Error(),
+				}
+			}
+
+			mutex.Lock()
+			containers[containerName(container)] = metrics
+			mutex.Unlock()
+		}(container)
+	}
+	wg.Wait()
+
+	// forget removed containers
+	c.mutex.Lock()
+	for id := range c.previousNetwork {
+		removed := true
+		for _, container := range list {
+			removed = removed && container.ID != id
+		}
+		if removed {
+			delete(c.previousNetwork, id)
+		}
+	}
+	c.mutex.Unlock()
+
+	data["docker.containers"] = containers
+
+	return data, nil
+}
+
+func (c *DockerCollector) getContainerMetrics(ctx context.Context, container dockerContainer) (map[string]interface{}, error) {
+
+	details := dockerContainerDetails{}
+	err := c.get(ctx, "/containers/"+container.ID+"/json", &details)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot inspect container")
+	}
+
+	metrics := map[string]interface{}{
+		"id":            container.ID,
+		"image":         container.Image,
+		"state":         container.State,
+		"restart_count": details.RestartCount,
+	}
+
+	// stats are only available for running containers
+	if container.State != "running" {
+		return metrics, nil
+	}
+
+	stats := dockerStats{}
+	err = c.get(ctx, "/containers/"+container.ID+"/stats?stream=false", &stats)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get container stats")
+	}
+
+	metrics["cpu_percent"] = containerCPUPercent(stats)
+	metrics["memory_usage"] = float64(stats.MemoryStats.Usage) / 1024 / 1024
+	metrics["memory_limit"] = float64(stats.MemoryStats.Limit) / 1024 / 1024
+	if stats.MemoryStats.Limit > 0 {
+		metrics["memory_percent"] = float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100
+	}
+
+	// network rates are computed from the previous poll
+	current := dockerNetworkCounters{Date: time.Now()}
+	for _, n := range stats.Networks {
+		current.RxBytes += n.RxBytes
+		current.TxBytes += n.TxBytes
+	}
+
+	c.mutex.Lock()
+	if previous, ok := c.previousNetwork[container.ID]; ok {
+		rates := counterSet{seconds: current.Date.Sub(previous.Date).Seconds()}
+		netIn := rates.rate(previous.RxBytes, current.RxBytes)
+		netOut := rates.rate(previous.TxBytes, current.TxBytes)
+		if !rates.reset {
+			metrics["net_in"] = netIn
+			metrics["net_out"] = netOut
+		}
+	}
+	c.previousNetwork[container.ID] = current
+	c.mutex.Unlock()
+
+	return metrics, nil
+}
+
+// containerCPUPercent computes the CPU usage like `docker stats`
+func containerCPUPercent(stats dockerStats) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+func containerName(c dockerContainer) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
+
+func (c *DockerCollector) get(ctx context.Context, path string, v interface{}) error {
+	// the host is ignored, requests are sent to the socket
+	req, err := http.NewRequest("GET", "http://docker"+path, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot create request to Docker API")
+	}
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "error with request to Docker API")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("Docker API responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDocker serves the Docker API on a unix socket
+type fakeDocker struct {
+	server     *httptest.Server
+	socket     string
+	containers []dockerContainer
+	// rxBytes are the network counters of the containers, by id
+	rxBytes map[string]uint64
+
+	mutex          sync.Mutex
+	inFlight       int
+	maxInFlight    int
+	statsRequested int
+}
+
+func newFakeDocker(t *testing.T, containers []dockerContainer) *fakeDocker {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeDocker{
+		socket:     filepath.Join(dir, "docker.sock"),
+		containers: containers,
+		rxBytes:    map[string]uint64{},
+	}
+
+	listener, err := net.Listen("unix", f.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.server = httptest.NewUnstartedServer(http.HandlerFunc(f.handle))
+	f.server.Listener = listener
+	f.server.Start()
+
+	return f
+}
+
+func (f *fakeDocker) setRxBytes(id string, value uint64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.rxBytes[id] = value
+}
+
+func (f *fakeDocker) close() {
+	f.server.Close()
+	os.RemoveAll(filepath.Dir(f.socket))
+}
+
+func (f *fakeDocker) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/containers/json" {
+		json.NewEncoder(w).Encode(f.containers)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "containers" {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[1]
+
+	switch parts[2] {
+	case "json":
+		if id == "broken" {
+			http.Error(w, "cannot inspect", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"RestartCount": 2}`)
+
+	case "stats":
+		f.mutex.Lock()
+		f.inFlight++
+		f.statsRequested++
+		if f.inFlight > f.maxInFlight {
+			f.maxInFlight = f.inFlight
+		}
+		rx := f.rxBytes[id]
+		f.mutex.Unlock()
+
+		// stats take some time to be computed by Docker
+		time.Sleep(time.Duration(20) * time.Millisecond)
+
+		fmt.Fprintf(w, `{
+			"cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 2000, "online_cpus": 2},
+			"precpu_stats": {"cpu_usage": {"total_usage": 100}, "system_cpu_usage": 1000},
+			"memory_stats": {"usage": 52428800, "limit": 104857600},
+			"networks": {"eth0": {"rx_bytes": %d, "tx_bytes": 0}}
+		}`, rx)
+
+		f.mutex.Lock()
+		f.inFlight--
+		f.mutex.Unlock()
+	}
+}
+
+func TestDockerCollector(t *testing.T) {
+	containers := []dockerContainer{
+		{ID: "web", Names: []string{"/web"}, Image: "nginx", State: "running"},
+		{ID: "job", Names: []string{"/job"}, Image: "alpine", State: "exited"},
+		{ID: "broken", Names: []string{"/broken"}, Image: "alpine", State: "running"},
+	}
+	docker := newFakeDocker(t, containers)
+	defer docker.close()
+
+	collector := NewDockerCollector(docker.socket)
+	data, err := collector.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := data["docker.containers"].(map[string]interface{})
+	if len(result) != 3 {
+		t.Fatalf("got %d containers, expected 3", len(result))
+	}
+
+	web := result["web"].(map[string]interface{})
+	if web["image"] != "nginx" || web["restart_count"] != 2 || web["cpu_percent"] != 40.0 || web["memory_percent"] != 50.0 {
+		t.Errorf("unexpected metrics of a running container: %v", web)
+	}
+	if _, ok := web["net_in"]; ok {
+		t.Error("network rates must be computed from the previous poll")
+	}
+
+	job := result["job"].(map[string]interface{})
+	if _, ok := job["cpu_percent"]; ok || job["state"] != "exited" {
+		t.Errorf("stats of a stopped container must not be requested: %v", job)
+	}
+
+	broken := result["broken"].(map[string]interface{})
+	if broken["state"] != "running" || !strings.Contains(broken["error"].(string), "cannot inspect") {
+		t.Errorf("the error of a container must be reported: %v", broken)
+	}
+}
+
+func TestDockerCollectorNetworkRates(t *testing.T) {
+	docker := newFakeDocker(t, []dockerContainer{{ID: "web", Names: []string{"/web"}, State: "running"}})
+	defer docker.close()
+
+	collector := NewDockerCollector(docker.socket)
+	collect := func() map[string]interface{} {
+		data, err := collector.Collect(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return data["docker.containers"].(map[string]interface{})["web"].(map[string]interface{})
+	}
+
+	docker.setRxBytes("web", 1000000)
+	collect()
+
+	docker.setRxBytes("web", 2000000)
+	if rate, ok := collect()["net_in"].(float64); !ok || rate <= 0 {
+		t.Errorf("got network rate %v, expected a positive rate", rate)
+	}
+
+	// the container was restarted, its counters are reset
+	docker.setRxBytes("web", 1000)
+	if rate, ok := collect()["net_in"]; ok {
+		t.Errorf("got network rate %v after a reset, expected no rate", rate)
+	}
+	docker.setRxBytes("web", 2000)
+	if rate, ok := collect()["net_in"].(float64); !ok || rate <= 0 || rate > 1000000 {
+		t.Errorf("got network rate %v, expected a rate from the new baseline", rate)
+	}
+}
+
+func TestDockerCollectorConcurrency(t *testing.T) {
+	containers := []dockerContainer{}
+	for i := 0; i < 5*dockerConcurrency; i++ {
+		containers = append(containers, dockerContainer{ID: fmt.Sprintf("c%d", i), State: "running"})
+	}
+	docker := newFakeDocker(t, containers)
+	defer docker.close()
+
+	_, err := NewDockerCollector(docker.socket).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if docker.statsRequested != len(containers) {
+		t.Errorf("got %d stats requests, expected %d", docker.statsRequested, len(containers))
+	}
+	if docker.maxInFlight > dockerConcurrency {
+		t.Errorf("got %d concurrent requests, expected at most %d", docker.maxInFlight, dockerConcurrency)
+	}
+}
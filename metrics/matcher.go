@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ProcessMatcher defines the processes to count, and the expected count
+type ProcessMatcher struct {
+	Label string
+	// Name is the exact name of the process
+	Name    string
+	Cmdline *regexp.Regexp
+	User    string
+	Min     int
+	// Max is the maximal count, -1 if there is no maximum
+	Max int
+}
+
+// keys of the fields of a process matcher
+var matcherKeys = []string{"name", "cmdline", "user", "min", "max"}
+
+// ParseProcessMatcher parses a matcher defined as `label:key=value;key=value`
+// where keys are `name`, `cmdline` (regular expression), `user`, `min` and `max`
+// i.e. `nginx:name=nginx;user=root;min=1`. The regular expression may contain `;`
+// when it is not followed by a key (i.e. `cmdline=^sh -c a;b`).
+func ParseProcessMatcher(definition string) (ProcessMatcher, error) {
+	matcher := ProcessMatcher{Min: 1, Max: -1}
+
+	parts := strings.SplitN(definition, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return matcher, errors.Errorf("invalid process matcher '%v': a label is required", definition)
+	}
+	matcher.Label = parts[0]
+
+	for _, field := range splitMatcherFields(parts[1]) {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return matcher, errors.Errorf("invalid process matcher '%v': '%v' must be key=value", definition, field)
+		}
+
+		var err error
+		switch kv[0] {
+		case "name":
+			matcher.Name = kv[1]
+		case "cmdline":
+			matcher.Cmdline, err = regexp.Compile(kv[1])
+		case "user":
+			matcher.User = kv[1]
+		case "min":
+			matcher.Min, err = strconv.Atoi(kv[1])
+		case "max":
+			matcher.Max, err = strconv.Atoi(kv[1])
+		default:
+			err = errors.Errorf("unknown key '%v'", kv[0])
+		}
+		if err != nil {
+			return matcher, errors.Wrapf(err, "invalid process matcher '%v'", definition)
+		}
+	}
+
+	if matcher.Name == "" && matcher.Cmdline == nil && matcher.User == "" {
+		return matcher, errors.Errorf("invalid process matcher '%v': name, cmdline or user is required", definition)
+	}
+
+	return matcher, nil
+}
+
+// splitMatcherFields splits the fields of a matcher on `;`, a `;` of the cmdline
+// which is not followed by a key is part of the regular expression
+func splitMatcherFields(definition string) []string {
+	fields := []string{}
+	for _, part := range strings.Split(definition, ";") {
+		if len(fields) > 0 && strings.HasPrefix(fields[len(fields)-1], "cmdline=") && !hasMatcherKey(part) {
+			fields[len(fields)-1] += ";" + part
+			continue
+		}
+		fields = append(fields, part)
+	}
+	return fields
+}
+
+// hasMatcherKey returns true if the field starts with a known key (i.e. `min=`)
+func hasMatcherKey(field string) bool {
+	for _, key := range matcherKeys {
+		if strings.HasPrefix(field, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ProcessMatcher) match(p *process.Process) bool {
+	if m.Name != "" {
+		if name, err := p.Name(); err != nil || name != m.Name {
+			return false
+		}
+	}
+	if m.User != "" {
+		if user, err := p.Username(); err != nil || user != m.User {
+			return false
+		}
+	}
+	if m.Cmdline != nil {
+		if cmdline, err := p.Cmdline(); err != nil || !m.Cmdline.MatchString(cmdline) {
+			return false
+		}
+	}
+	return true
+}
+
+// ProcessMatchersCollector counts the processes matching each matcher
+type ProcessMatchersCollector struct {
+	matchers []ProcessMatcher
+}
+
+// NewProcessMatchersCollector returns a collector counting the processes of each matcher
+func NewProcessMatchersCollector(matchers []ProcessMatcher) *ProcessMatchersCollector {
+	return &ProcessMatchersCollector{matchers: matchers}
+}
+
+// Name returns the name of the collector
+func (c *ProcessMatchersCollector) Name() string {
+	return "process_matchers"
+}
+
+// Collect returns the count of processes for each matcher,
+// along with the expected counts
+func (c *ProcessMatchersCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot get processes")
+	}
+
+	matchers := map[string]interface{}{}
+	for i := range c.matchers {
+		m := &c.matchers[i]
+
+		count := 0
+		for _, p := range processes {
+			if m.match(p) {
+				count++
+			}
+		}
+
+		result := map[string]interface{}{
+			"count": count,
+			"min":   m.Min,
+		}
+		if m.Max >= 0 {
+			result["max"] = m.Max
+		}
+		matchers[m.Label] = result
+	}
+
+	data["processes.matchers"] = matchers
+
+	return data, nil
+}
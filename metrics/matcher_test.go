@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProcessMatcher(t *testing.T) {
+	tests := []struct {
+		definition string
+		label      string
+		name       string
+		cmdline    string
+		user       string
+		min        int
+		max        int
+		err        string
+	}{
+		{definition: "nginx:name=nginx;min=2", label: "nginx", name: "nginx", min: 2, max: -1},
+		{definition: "workers:cmdline=^php .*worker;user=www-data;max=8", label: "workers", cmdline: "^php .*worker", user: "www-data", min: 1, max: 8},
+		{definition: "sh:cmdline=^sh -c a;b$", label: "sh", cmdline: "^sh -c a;b$", min: 1, max: -1},
+		{definition: "sh:cmdline=a;b;c;user=root;min=0", label: "sh", cmdline: "a;b;c", user: "root", min: 0, max: -1},
+		{definition: "sh:user=root;cmdline=a;;b", label: "sh", cmdline: "a;;b", user: "root", min: 1, max: -1},
+		{definition: "name=nginx", err: "a label is required"},
+		{definition: "nginx:name=nginx;typo", err: "'typo' must be key=value"},
+		{definition: "nginx:name=nginx;owner=root", err: "unknown key 'owner'"},
+		{definition: "nginx:min=2", err: "name, cmdline or user is required"},
+		{definition: "nginx:cmdline=(;user=root", err: "error parsing regexp"},
+		{definition: "nginx:name=nginx;min=two", err: "invalid syntax"},
+	}
+
+	for _, test := range tests {
+		matcher, err := ParseProcessMatcher(test.definition)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%v: got error %v, expected %q", test.definition, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.definition, err)
+			continue
+		}
+
+		cmdline := ""
+		if matcher.Cmdline != nil {
+			cmdline = matcher.Cmdline.String()
+		}
+		if matcher.Label != test.label || matcher.Name != test.name || cmdline != test.cmdline ||
+			matcher.User != test.user || matcher.Min != test.min || matcher.Max != test.max {
+			t.Errorf("%v: got %+v (cmdline %q)", test.definition, matcher, cmdline)
+		}
+	}
+}
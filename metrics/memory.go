@@ -1,23 +1,74 @@
 package metrics
 
 import (
+	"context"
+	"time"
 	"webup/syshealth"
 
 	"github.com/pkg/errors"
 	"github.com/shirou/gopsutil/mem"
 )
 
-func GetMemory() (syshealth.Data, error) {
+// MemoryCollector collects the memory and swap usage
+type MemoryCollector struct {
+	// swap counters of the previous poll, used to compute rates
+	previousSwap     *mem.SwapMemoryStat
+	previousSwapDate time.Time
+}
+
+// NewMemoryCollector returns a collector of memory and swap usage
+func NewMemoryCollector() *MemoryCollector {
+	return &MemoryCollector{}
+}
+
+// Name returns the name of the collector
+func (c *MemoryCollector) Name() string {
+	return "memory"
+}
+
+// Collect returns the memory and swap usage, and the swap activity
+func (c *MemoryCollector) Collect(ctx context.Context) (syshealth.Data, error) {
 
 	data := syshealth.Data{}
 
-	v, err := mem.VirtualMemory()
+	v, err := mem.VirtualMemoryWithContext(ctx)
 	if err != nil {
 		return data, errors.Wrap(err, "cannot get virtual memory")
 	}
 
-	data["memory.available"] = float64(v.Available) / 1024.0 / 1024.0 / 1024.0 // from bytes to gigabytes
+	data["memory.available"] = toGigabytes(v.Available)
 	data["memory.used_percent"] = v.UsedPercent
+	data["memory.total"] = toGigabytes(v.Total)
+	data["memory.buffers"] = toGigabytes(v.Buffers)
+	data["memory.cached"] = toGigabytes(v.Cached)
+
+	s, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot get swap memory")
+	}
+
+	data["memory.swap_total"] = toGigabytes(s.Total)
+	data["memory.swap_used"] = toGigabytes(s.Used)
+	data["memory.swap_percent"] = s.UsedPercent
+
+	// swap activity (in bytes per second), computed from the previous poll
+	now := time.Now()
+	if c.previousSwap != nil {
+		rates := counterSet{seconds: now.Sub(c.previousSwapDate).Seconds()}
+		swapIn := rates.rate(c.previousSwap.Sin, s.Sin)
+		swapOut := rates.rate(c.previousSwap.Sout, s.Sout)
+		if !rates.reset {
+			data["memory.swap_in"] = swapIn
+			data["memory.swap_out"] = swapOut
+		}
+	}
+	c.previousSwap = s
+	c.previousSwapDate = now
 
 	return data, nil
 }
+
+// toGigabytes converts bytes to gigabytes
+func toGigabytes(bytes uint64) float64 {
+	return float64(bytes) / 1024.0 / 1024.0 / 1024.0
+}
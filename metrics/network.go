@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"path"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/net"
+)
+
+// NetworkCollector collects the traffic of network interfaces
+type NetworkCollector struct {
+	// interfaces patterns (i.e. eth*)
+	include []string
+	exclude []string
+
+	// counters of the previous poll, used to compute rates
+	previousCounters map[string]net.IOCountersStat
+	previousDate     time.Time
+}
+
+// NewNetworkCollector returns a collector of network traffic. The patterns (i.e. eth*)
+// select the interfaces to include or exclude. All interfaces are included if `include` is empty.
+func NewNetworkCollector(include []string, exclude []string) *NetworkCollector {
+	return &NetworkCollector{
+		include:          include,
+		exclude:          exclude,
+		previousCounters: map[string]net.IOCountersStat{},
+	}
+}
+
+// Name returns the name of the collector
+func (c *NetworkCollector) Name() string {
+	return "network"
+}
+
+// Collect returns the traffic rates of each watched interface
+func (c *NetworkCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot get network counters")
+	}
+
+	now := time.Now()
+	seconds := now.Sub(c.previousDate).Seconds()
+
+	interfaces := map[string]interface{}{}
+	current := map[string]net.IOCountersStat{}
+
+	for _, counter := range counters {
+		if !c.isWatched(counter.Name) {
+			continue
+		}
+		current[counter.Name] = counter
+
+		// rates are computed from the previous poll
+		p, ok := c.previousCounters[counter.Name]
+		if !ok {
+			continue
+		}
+
+		rates := counterSet{seconds: seconds}
+		metrics := map[string]interface{}{
+			"bytes_in":    rates.rate(p.BytesRecv, counter.BytesRecv),
+			"bytes_out":   rates.rate(p.BytesSent, counter.BytesSent),
+			"packets_in":  rates.rate(p.PacketsRecv, counter.PacketsRecv),
+			"packets_out": rates.rate(p.PacketsSent, counter.PacketsSent),
+			"errors_in":   rates.rate(p.Errin, counter.Errin),
+			"errors_out":  rates.rate(p.Errout, counter.Errout),
+			"drops_in":    rates.rate(p.Dropin, counter.Dropin),
+			"drops_out":   rates.rate(p.Dropout, counter.Dropout),
+		}
+		if !rates.reset {
+			interfaces[counter.Name] = metrics
+		}
+	}
+
+	c.previousCounters = current
+	c.previousDate = now
+
+	data["net.interfaces"] = interfaces
+
+	return data, nil
+}
+
+func (c *NetworkCollector) isWatched(name string) bool {
+	for _, pattern := range c.exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return false
+		}
+	}
+
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, pattern := range c.include {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+// readPressure reads a Pressure Stall Information file, made of lines like:
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//
+// and returns the averages by key (i.e. some_avg10)
+func readPressure(path string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read pressure file")
+	}
+
+	pressure := map[string]interface{}{}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		kind := fields[0]
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || !strings.HasPrefix(kv[0], "avg") {
+				continue
+			}
+			value, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid pressure value in %v", path)
+			}
+			pressure[kind+"_"+kv[0]] = value
+		}
+	}
+
+	return pressure, nil
+}
+
+const pressureRoot = "/proc/pressure"
+
+// PressureCollector collects the Pressure Stall Information of the system
+type PressureCollector struct{}
+
+// NewPressureCollector returns a collector of Pressure Stall Information
+func NewPressureCollector() *PressureCollector {
+	return &PressureCollector{}
+}
+
+// Name returns the name of the collector
+func (c *PressureCollector) Name() string {
+	return "pressure"
+}
+
+// Collect returns the Pressure Stall Information of the system,
+// if supported by the kernel (4.20+)
+func (c *PressureCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	if _, err := os.Stat(pressureRoot); os.IsNotExist(err) {
+		return data, nil
+	}
+
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		pressure, err := readPressure(filepath.Join(pressureRoot, resource))
+		if err != nil {
+			return data, errors.Wrapf(err, "cannot get %v pressure", resource)
+		}
+		data["pressure."+resource] = pressure
+	}
+
+	return data, nil
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+// ProbePrefix is the prefix of the keys of the probe results (i.e. `probes.api`)
+const ProbePrefix = "probes."
+
+// only the beginning of the body is matched
+const maxProbeBody = 1024 * 1024
+
+// Probe defines a HTTP(S) request and its expected response
+type Probe struct {
+	Name   string
+	URL    string
+	Method string
+	// ExpectedStatus is the status of the response, any status below 400 if 0
+	ExpectedStatus int
+	// Body must match the body of the response, if not nil
+	Body               *regexp.Regexp
+	InsecureSkipVerify bool
+}
+
+// ProbeCollector sends a HTTP(S) request and checks the response
+type ProbeCollector struct {
+	probe  Probe
+	client *http.Client
+}
+
+// NewProbeCollector returns a collector sending the request of the probe,
+// the timeout of the request is the timeout of the collector
+func NewProbeCollector(probe Probe) *ProbeCollector {
+	return &ProbeCollector{
+		probe: probe,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: probe.InsecureSkipVerify},
+				// each request opens a new connection, so that the latency is comparable
+				DisableKeepAlives: true,
+			},
+		},
+	}
+}
+
+// Name returns the name of the collector (i.e. `probes.api`)
+func (c *ProbeCollector) Name() string {
+	return ProbePrefix + c.probe.Name
+}
+
+// Collect sends the request and returns the state of the endpoint. An endpoint
+// which cannot be reached is down, this is not an error of the collector.
+func (c *ProbeCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	method := c.probe.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	req, err := http.NewRequest(method, c.probe.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create request")
+	}
+
+	result := map[string]interface{}{
+		"url": c.probe.URL,
+	}
+
+	start := time.Now()
+	err = c.probe.check(c.client, req.WithContext(ctx), result)
+	result["latency"] = time.Now().Sub(start).Seconds()
+
+	result["up"] = err == nil
+	if err != nil {
+		result["error"] = err.Error()
+	}
+
+	return syshealth.Data{c.Name(): result}, nil
+}
+
+// check sends the request and checks the response, its status and its TLS expiry are added to the result
+func (p *Probe) check(client *http.Client, req *http.Request, result map[string]interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result["status"] = resp.StatusCode
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		result["tls_expiry"] = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	if p.ExpectedStatus > 0 && resp.StatusCode != p.ExpectedStatus {
+		return errors.Errorf("status %d, %d expected", resp.StatusCode, p.ExpectedStatus)
+	}
+	if p.ExpectedStatus == 0 && resp.StatusCode >= 400 {
+		return errors.Errorf("status %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return errors.Wrap(err, "unable to read body")
+	}
+	if p.Body != nil && !p.Body.Match(body) {
+		return errors.Errorf("body does not match '%v'", p.Body)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func probeHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			return
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Duration(5) * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	return mux
+}
+
+func runProbe(t *testing.T, probe Probe, timeout time.Duration) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	data, err := NewProbeCollector(probe).Collect(ctx)
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", probe.Name, err)
+	}
+	return data["probes."+probe.Name].(map[string]interface{})
+}
+
+func TestProbeResponse(t *testing.T) {
+	server := httptest.NewServer(probeHandler())
+	defer server.Close()
+
+	tests := []struct {
+		probe  Probe
+		up     bool
+		status interface{}
+		err    string
+	}{
+		{Probe{Name: "up", URL: server.URL + "/health"}, true, 200, ""},
+		{Probe{Name: "head", URL: server.URL + "/health", Method: "HEAD", ExpectedStatus: 200}, true, 200, ""},
+		{Probe{Name: "error status", URL: server.URL + "/unavailable"}, false, 503, "status 503"},
+		{Probe{Name: "status mismatch", URL: server.URL + "/health", ExpectedStatus: 204}, false, 200, "status 200, 204 expected"},
+		{Probe{Name: "expected error status", URL: server.URL + "/unavailable", ExpectedStatus: 503}, true, 503, ""},
+		{Probe{Name: "body match", URL: server.URL + "/health", Body: regexp.MustCompile(`"status":\s*"ok"`)}, true, 200, ""},
+		{Probe{Name: "body mismatch", URL: server.URL + "/health", Body: regexp.MustCompile(`"status":\s*"degraded"`)}, false, 200, "body does not match"},
+		{Probe{Name: "refused", URL: "http://127.0.0.1:1/"}, false, nil, "connection refused"},
+	}
+
+	for _, test := range tests {
+		result := runProbe(t, test.probe, time.Duration(5)*time.Second)
+
+		if result["up"] != test.up || result["status"] != test.status {
+			t.Errorf("%v: got up %v and status %v, expected %v and %v", test.probe.Name, result["up"], result["status"], test.up, test.status)
+		}
+		if test.err == "" && result["error"] != nil {
+			t.Errorf("%v: unexpected error %v", test.probe.Name, result["error"])
+		}
+		if test.err != "" && !strings.Contains(fmt.Sprint(result["error"]), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.probe.Name, result["error"], test.err)
+		}
+		if latency, ok := result["latency"].(float64); !ok || latency < 0 {
+			t.Errorf("%v: invalid latency %v", test.probe.Name, result["latency"])
+		}
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	server := httptest.NewServer(probeHandler())
+	defer server.Close()
+
+	start := time.Now()
+	result := runProbe(t, Probe{Name: "slow", URL: server.URL + "/slow"}, time.Duration(200)*time.Millisecond)
+
+	if result["up"] != false || !strings.Contains(fmt.Sprint(result["error"]), "deadline exceeded") {
+		t.Errorf("got up %v and error %v, expected a timeout", result["up"], result["error"])
+	}
+	if elapsed := time.Now().Sub(start); elapsed > time.Second {
+		t.Errorf("the probe returned after %v", elapsed)
+	}
+}
+
+func TestProbeTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(probeHandler())
+	// handshakes rejected by the probe are not logged
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	// the certificate of the test server is not trusted
+	result := runProbe(t, Probe{Name: "untrusted", URL: server.URL + "/health"}, time.Duration(5)*time.Second)
+	if result["up"] != false || !strings.Contains(fmt.Sprint(result["error"]), "certificate") {
+		t.Errorf("got up %v and error %v, expected a certificate error", result["up"], result["error"])
+	}
+
+	result = runProbe(t, Probe{Name: "tls", URL: server.URL + "/health", InsecureSkipVerify: true}, time.Duration(5)*time.Second)
+	if result["up"] != true {
+		t.Fatalf("got error %v, expected the endpoint to be up", result["error"])
+	}
+
+	expiry, ok := result["tls_expiry"].(time.Time)
+	if !ok || !expiry.Equal(server.Certificate().NotAfter) {
+		t.Errorf("got TLS expiry %v, expected %v", result["tls_expiry"], server.Certificate().NotAfter)
+	}
+}
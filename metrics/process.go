@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"sort"
+	"time"
+	"unicode/utf8"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+	"github.com/shirou/gopsutil/process"
+)
+
+// maximal length of the command lines sent (in bytes)
+const maxCmdlineLength = 200
+
+// TopProcessesCollector collects the processes using the most CPU and memory
+type TopProcessesCollector struct {
+	// number of processes sent for each category
+	count int
+
+	// CPU times of the previous poll, used to compute CPU usage
+	previousTimes map[int32]float64
+	previousDate  time.Time
+}
+
+// NewTopProcessesCollector returns a collector sending `count` processes by CPU and by memory usage
+func NewTopProcessesCollector(count int) *TopProcessesCollector {
+	return &TopProcessesCollector{
+		count:         count,
+		previousTimes: map[int32]float64{},
+	}
+}
+
+// Name returns the name of the collector
+func (c *TopProcessesCollector) Name() string {
+	return "processes"
+}
+
+// Collect returns the processes using the most CPU and memory
+func (c *TopProcessesCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	processes, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return data, errors.Wrap(err, "cannot get processes")
+	}
+
+	now := time.Now()
+	seconds := now.Sub(c.previousDate).Seconds()
+
+	type usage struct {
+		process *process.Process
+		cpu     float64
+		memory  float64
+	}
+
+	usages := []usage{}
+	times := map[int32]float64{}
+
+	for _, p := range processes {
+		// processes may have exited since the listing
+		t, err := p.TimesWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		m, err := p.MemoryInfoWithContext(ctx)
+		if err != nil {
+			continue
+		}
+
+		u := usage{process: p, memory: float64(m.RSS) / 1024 / 1024}
+
+		// CPU usage is computed from the previous poll
+		times[p.Pid] = t.User + t.System
+		if previous, ok := c.previousTimes[p.Pid]; ok && seconds > 0 && times[p.Pid] >= previous {
+			u.cpu = (times[p.Pid] - previous) / seconds * 100
+		}
+
+		usages = append(usages, u)
+	}
+
+	c.previousTimes = times
+	c.previousDate = now
+
+	top := syshealth.TopProcesses{}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].cpu > usages[j].cpu })
+	for i := 0; i < len(usages) && i < c.count; i++ {
+		top.CPU = append(top.CPU, describeProcess(usages[i].process, usages[i].cpu, usages[i].memory))
+	}
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].memory > usages[j].memory })
+	for i := 0; i < len(usages) && i < c.count; i++ {
+		top.Memory = append(top.Memory, describeProcess(usages[i].process, usages[i].cpu, usages[i].memory))
+	}
+
+	data[syshealth.TopProcessesKey] = top
+
+	return data, nil
+}
+
+func describeProcess(p *process.Process, cpu float64, memory float64) syshealth.Process {
+	// errors are ignored, the process description is informative only
+	name, _ := p.Name()
+	user, _ := p.Username()
+	cmdline, _ := p.Cmdline()
+	cmdline = truncateCmdline(cmdline)
+
+	return syshealth.Process{
+		PID:     p.Pid,
+		Name:    name,
+		User:    user,
+		Cmdline: cmdline,
+		CPU:     cpu,
+		Memory:  memory,
+	}
+}
+
+// truncateCmdline truncates the command line to the maximal length,
+// without splitting a multi-byte character
+func truncateCmdline(cmdline string) string {
+	if len(cmdline) <= maxCmdlineLength {
+		return cmdline
+	}
+
+	end := maxCmdlineLength
+	for end > 0 && !utf8.RuneStart(cmdline[end]) {
+		end--
+	}
+	return cmdline[:end] + "…"
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateCmdline(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmdline  string
+		expected string
+	}{
+		{"short", "nginx -g daemon off;", "nginx -g daemon off;"},
+		{"maximal length", strings.Repeat("a", maxCmdlineLength), strings.Repeat("a", maxCmdlineLength)},
+		{"long", strings.Repeat("a", maxCmdlineLength+1), strings.Repeat("a", maxCmdlineLength) + "…"},
+		// "é" is 2 bytes long, the limit falls in its middle
+		{"multi-byte character", strings.Repeat("a", maxCmdlineLength-1) + "éé", strings.Repeat("a", maxCmdlineLength-1) + "…"},
+		// "日" is 3 bytes long
+		{"characters only", strings.Repeat("日", maxCmdlineLength), strings.Repeat("日", maxCmdlineLength/3) + "…"},
+	}
+
+	for _, test := range tests {
+		truncated := truncateCmdline(test.cmdline)
+		if truncated != test.expected {
+			t.Errorf("%v: got %q, expected %q", test.name, truncated, test.expected)
+		}
+		if !utf8.ValidString(truncated) {
+			t.Errorf("%v: got invalid UTF-8 %q", test.name, truncated)
+		}
+	}
+}
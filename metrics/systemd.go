@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"webup/syshealth"
+
+	"github.com/pkg/errors"
+)
+
+const systemctlTimeout = time.Duration(10) * time.Second
+
+// SystemdCollector collects the state of systemd units
+type SystemdCollector struct {
+	// units always reported, failed units are reported too
+	units []string
+}
+
+// NewSystemdCollector returns a collector of the state of the given units.
+// Failed units are always reported.
+func NewSystemdCollector(units []string) *SystemdCollector {
+	return &SystemdCollector{units: units}
+}
+
+// Name returns the name of the collector
+func (c *SystemdCollector) Name() string {
+	return "systemd"
+}
+
+// Collect returns the state of the configured and failed systemd units
+func (c *SystemdCollector) Collect(ctx context.Context) (syshealth.Data, error) {
+
+	data := syshealth.Data{}
+
+	failed, err := systemctl(ctx, "list-units", "--state=failed", "--all", "--plain", "--no-legend")
+	if err != nil {
+		return data, errors.Wrap(err, "cannot list failed units")
+	}
+
+	units := append([]string{}, c.units...)
+	scanner := bufio.NewScanner(strings.NewReader(failed))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+
+	output := ""
+	if len(units) > 0 {
+		args := append([]string{"show", "--property=Id,LoadState,ActiveState,SubState,NRestarts"}, units...)
+		output, err = systemctl(ctx, args...)
+		if err != nil {
+			return data, errors.Wrap(err, "cannot get units state")
+		}
+	}
+
+	states, failedCount := unitStates(output)
+	data["systemd.units"] = states
+	data["systemd.failed"] = failedCount
+
+	return data, nil
+}
+
+// unitStates returns the state of each unit shown by `systemctl show`, and the count
+// of failed units (a configured unit which failed is shown twice, it is counted once)
+func unitStates(output string) (map[string]interface{}, int) {
+	states := map[string]interface{}{}
+	failedCount := 0
+
+	for _, properties := range parseSystemctlShow(output) {
+		id := properties["Id"]
+		if _, ok := states[id]; ok || id == "" {
+			continue
+		}
+
+		// NRestarts is not available before systemd 235
+		restarts, _ := strconv.Atoi(properties["NRestarts"])
+		isFailed := properties["ActiveState"] == "failed"
+		if isFailed {
+			failedCount++
+		}
+
+		states[id] = map[string]interface{}{
+			"load_state":   properties["LoadState"],
+			"active_state": properties["ActiveState"],
+			"sub_state":    properties["SubState"],
+			"restarts":     restarts,
+			"failed":       isFailed,
+		}
+	}
+
+	return states, failedCount
+}
+
+func systemctl(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		return "", errors.Wrap(err, "systemctl failed")
+	}
+	return string(output), nil
+}
+
+// parseSystemctlShow parses the output of `systemctl show`, made of
+// `key=value` lines with a blank line between each unit
+func parseSystemctlShow(output string) []map[string]string {
+	units := []map[string]string{}
+	current := map[string]string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if len(current) > 0 {
+				units = append(units, current)
+				current = map[string]string{}
+			}
+			continue
+		}
+		if kv := strings.SplitN(line, "=", 2); len(kv) == 2 {
+			current[kv[0]] = kv[1]
+		}
+	}
+	if len(current) > 0 {
+		units = append(units, current)
+	}
+
+	return units
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+const systemctlShowOutput = `Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+NRestarts=2
+
+Id=backup.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+NRestarts=0
+
+
+Id=old.service
+LoadState=loaded
+ActiveState=inactive
+SubState=dead
+
+Id=backup.service
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+NRestarts=0
+`
+
+func TestParseSystemctlShow(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected []map[string]string
+	}{
+		{"no unit", "", []map[string]string{}},
+		{"single unit without trailing line", "Id=a.service\nActiveState=active", []map[string]string{
+			{"Id": "a.service", "ActiveState": "active"},
+		}},
+		{"units separated by blank lines", "Id=a.service\nActiveState=active\n\n\nId=b.service\nActiveState=failed\n\n", []map[string]string{
+			{"Id": "a.service", "ActiveState": "active"},
+			{"Id": "b.service", "ActiveState": "failed"},
+		}},
+		{"missing and empty properties", "Id=a.service\nSubState=\ninvalid line\nDescription=a=b\n", []map[string]string{
+			{"Id": "a.service", "SubState": "", "Description": "a=b"},
+		}},
+	}
+
+	for _, test := range tests {
+		units := parseSystemctlShow(test.output)
+		if !reflect.DeepEqual(units, test.expected) {
+			t.Errorf("%v: got %v, expected %v", test.name, units, test.expected)
+		}
+	}
+}
+
+func TestUnitStates(t *testing.T) {
+	states, failedCount := unitStates(systemctlShowOutput)
+
+	expected := map[string]interface{}{
+		"nginx.service":  map[string]interface{}{"load_state": "loaded", "active_state": "active", "sub_state": "running", "restarts": 2, "failed": false},
+		"backup.service": map[string]interface{}{"load_state": "loaded", "active_state": "failed", "sub_state": "failed", "restarts": 0, "failed": true},
+		// NRestarts is not available before systemd 235
+		"old.service": map[string]interface{}{"load_state": "loaded", "active_state": "inactive", "sub_state": "dead", "restarts": 0, "failed": false},
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("got states %v, expected %v", states, expected)
+	}
+	if failedCount != 1 {
+		t.Errorf("got %d failed units, expected 1", failedCount)
+	}
+}
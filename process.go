@@ -0,0 +1,44 @@
+package syshealth
+
+import "encoding/json"
+
+// Process represents a process running on a server
+type Process struct {
+	PID     int32  `json:"pid"`
+	Name    string `json:"name"`
+	User    string `json:"user"`
+	Cmdline string `json:"cmdline"`
+	// CPU is the percent of a core used by the process
+	CPU float64 `json:"cpu"`
+	// Memory is the resident memory (in MB)
+	Memory float64 `json:"memory"`
+}
+
+// TopProcesses contains the processes using the most CPU and memory
+type TopProcesses struct {
+	CPU    []Process `json:"cpu"`
+	Memory []Process `json:"memory"`
+}
+
+// TopProcessesKey is the metric key of the top processes snapshot
+const TopProcessesKey = "processes.top"
+
+// GetTopProcesses returns the top processes snapshot contained in metrics, if any
+func GetTopProcesses(metrics Data) *TopProcesses {
+	raw, ok := metrics[TopProcessesKey]
+	if !ok {
+		return nil
+	}
+
+	// metrics received by the server are decoded as generic maps
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	top := new(TopProcesses)
+	if err := json.Unmarshal(b, top); err != nil {
+		return nil
+	}
+
+	return top
+}
@@ -0,0 +1,102 @@
+package bolt
+
+import (
+	"webup/syshealth"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/pkg/errors"
+)
+
+var (
+	bucketAgentConfigs = []byte("agent_configs")
+)
+
+// GetAgentConfigRepository returns a new bolt repository of the configurations pushed to agents
+func GetAgentConfigRepository(databaseDir string) syshealth.AgentConfigRepository {
+	repo := agentConfigRepository{
+		databaseDir: databaseDir,
+	}
+	return &repo
+}
+
+type agentConfigRepository struct {
+	databaseDir string
+}
+
+func (repo *agentConfigRepository) GetAll() (map[string]string, error) {
+	db, err := GetConnection(repo.databaseDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open bolt db")
+	}
+
+	configs := map[string]string{}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAgentConfigs)
+
+		// if the bucket doesn't exist, just return an empty map.
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			configs[string(k)] = string(v)
+			return nil
+		})
+	})
+
+	return configs, err
+}
+
+func (repo *agentConfigRepository) Get(scope string) (string, error) {
+	db, err := GetConnection(repo.databaseDir)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to open bolt db")
+	}
+
+	config := ""
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAgentConfigs)
+		if b == nil {
+			return nil
+		}
+
+		config = string(b.Get([]byte(scope)))
+		return nil
+	})
+
+	return config, err
+}
+
+func (repo *agentConfigRepository) Set(scope string, config string) error {
+	db, err := GetConnection(repo.databaseDir)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bolt db")
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketAgentConfigs)
+		if err != nil {
+			return errors.Wrap(err, "cannot create or get bucket for 'agent_configs'")
+		}
+
+		return b.Put([]byte(scope), []byte(config))
+	})
+}
+
+func (repo *agentConfigRepository) Delete(scope string) error {
+	db, err := GetConnection(repo.databaseDir)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bolt db")
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAgentConfigs)
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(scope))
+	})
+}
@@ -161,6 +161,43 @@ func (repo *serverRepository) GetServer(id string) (*syshealth.Server, error) {
 	return server, err
 }
 
+func (repo *serverRepository) SetServerGroup(id string, group string) error {
+
+	db, err := GetConnection(repo.databaseDir)
+	if err != nil {
+		return errors.Wrap(err, "unable to open bolt db")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketServers)
+		if b == nil {
+			return errors.Errorf("server '%v' is not registered", id)
+		}
+
+		data := b.Get([]byte(id))
+		if data == nil {
+			return errors.Errorf("server '%v' is not registered", id)
+		}
+
+		server := syshealth.Server{}
+		err := json.Unmarshal(data, &server)
+		if err != nil {
+			return errors.Wrap(err, "cannot unmarshal server data for bolt db")
+		}
+
+		server.Group = group
+
+		buf, err := json.Marshal(server)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal server data into json")
+		}
+
+		return b.Put([]byte(id), buf)
+	})
+
+	return err
+}
+
 // servers sorting
 
 type serversByName []syshealth.Server
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"sync"
+	"webup/syshealth"
+)
+
+// GetAgentRepository returns a new in-memory agent repository
+func GetAgentRepository() syshealth.AgentRepository {
+	repo := agentRepository{
+		agentsByServerID: map[string]syshealth.Agent{},
+	}
+	return &repo
+}
+
+type agentRepository struct {
+	agentsByServerID map[string]syshealth.Agent
+	mutex            sync.RWMutex
+}
+
+// Get returns the agent of the server, nil if the server has never sent metrics
+func (repo *agentRepository) Get(serverID string) (*syshealth.Agent, error) {
+	repo.mutex.RLock()
+	defer repo.mutex.RUnlock()
+
+	if agent, ok := repo.agentsByServerID[serverID]; ok {
+		return &agent, nil
+	}
+	return nil, nil
+}
+
+func (repo *agentRepository) Store(serverID string, agent syshealth.Agent) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+
+	repo.agentsByServerID[serverID] = agent
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package rule
+
+import (
+	"math"
+	"webup/syshealth/history"
+)
+
+// function computes a value over history points
+type function func(points []history.Data, read history.ValueReader) (interface{}, bool)
+
+var functions = map[string]function{
+	"avg_over":  avgOver,
+	"min_over":  minOver,
+	"max_over":  maxOver,
+	"rate_over": rateOver,
+}
+
+func values(points []history.Data, read history.ValueReader) []float64 {
+	values := []float64{}
+	for _, p := range points {
+		if v, ok := read(p.Value); ok && !math.IsNaN(v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func avgOver(points []history.Data, read history.ValueReader) (interface{}, bool) {
+	values := values(points, read)
+	if len(values) == 0 {
+		return nil, false
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), true
+}
+
+func minOver(points []history.Data, read history.ValueReader) (interface{}, bool) {
+	values := values(points, read)
+	if len(values) == 0 {
+		return nil, false
+	}
+	min := values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+	}
+	return min, true
+}
+
+func maxOver(points []history.Data, read history.ValueReader) (interface{}, bool) {
+	values := values(points, read)
+	if len(values) == 0 {
+		return nil, false
+	}
+	max := values[0]
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	return max, true
+}
+
+// rateOver returns the variation per hour
+func rateOver(points []history.Data, read history.ValueReader) (interface{}, bool) {
+	trend, ok := history.NewTrend(points, read)
+	if !ok {
+		return nil, false
+	}
+	return trend.PerHour(), true
+}
@@ -0,0 +1,189 @@
+package rule
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenDuration
+	tokenString
+	tokenComparison
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenFor
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+	tokenDot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var keywords = map[string]tokenKind{
+	"and": tokenAnd,
+	"or":  tokenOr,
+	"not": tokenNot,
+	"for": tokenFor,
+}
+
+var symbols = map[string]tokenKind{
+	"&&": tokenAnd,
+	"||": tokenOr,
+	">=": tokenComparison,
+	"<=": tokenComparison,
+	"==": tokenComparison,
+	"!=": tokenComparison,
+	">":  tokenComparison,
+	"<":  tokenComparison,
+	"!":  tokenNot,
+	"(":  tokenLeftParen,
+	")":  tokenRightParen,
+	"[":  tokenLeftBracket,
+	"]":  tokenRightBracket,
+	",":  tokenComma,
+	".":  tokenDot,
+}
+
+// lex splits the source of an expression into tokens
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(source)
+
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+
+		case isDigit(r) || r == '-' && pos+1 < len(runes) && isDigit(runes[pos+1]):
+			t, end, err := lexNumber(runes, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, t)
+			pos = end
+
+		case isIdentStart(r):
+			// identifiers may contain dots (i.e. cpu.load_5)
+			start := pos
+			for pos < len(runes) && (isIdentStart(runes[pos]) || isDigit(runes[pos]) || runes[pos] == '.') {
+				pos++
+			}
+			text := strings.TrimRight(string(runes[start:pos]), ".")
+			pos = start + len([]rune(text))
+			if kind, ok := keywords[strings.ToLower(text)]; ok {
+				tokens = append(tokens, token{kind: kind, text: text, pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdent, text: text, pos: start})
+			}
+
+		case r == '"':
+			start := pos
+			pos++
+			for pos < len(runes) && runes[pos] != '"' {
+				pos++
+			}
+			if pos == len(runes) {
+				return nil, errors.Errorf("unterminated string at position %d", start)
+			}
+			pos++
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start+1 : pos-1]), pos: start})
+
+		default:
+			matched := false
+			// try two characters symbols first
+			for _, size := range []int{2, 1} {
+				if pos+size > len(runes) {
+					continue
+				}
+				text := string(runes[pos : pos+size])
+				if kind, ok := symbols[text]; ok {
+					tokens = append(tokens, token{kind: kind, text: text, pos: pos})
+					pos += size
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.Errorf("unexpected character %q at position %d", r, pos)
+			}
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+
+	return tokens, nil
+}
+
+// lexNumber reads a number (i.e. 1.5 or 1e5) or a duration, made of numbers
+// followed by units (i.e. 5m or 1h30m), starting at `start`
+func lexNumber(runes []rune, start int) (token, int, error) {
+	pos := start + 1
+	digits := func() {
+		for pos < len(runes) && (isDigit(runes[pos]) || runes[pos] == '.') {
+			pos++
+		}
+	}
+	digits()
+
+	// exponent (only for numbers)
+	if pos < len(runes) && (runes[pos] == 'e' || runes[pos] == 'E') {
+		exponent := pos + 1
+		if exponent < len(runes) && (runes[exponent] == '+' || runes[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(runes) && isDigit(runes[exponent]) {
+			pos = exponent
+			for pos < len(runes) && isDigit(runes[pos]) {
+				pos++
+			}
+			if pos < len(runes) && isIdentStart(runes[pos]) {
+				return token{}, 0, errors.Errorf("invalid number %q at position %d", string(runes[start:pos+1]), start)
+			}
+			return token{kind: tokenNumber, text: string(runes[start:pos]), pos: start}, pos, nil
+		}
+	}
+
+	if pos == len(runes) || !unicode.IsLetter(runes[pos]) {
+		return token{kind: tokenNumber, text: string(runes[start:pos]), pos: start}, pos, nil
+	}
+
+	// units, then other numbers and units (i.e. 1h30m)
+	for pos < len(runes) && unicode.IsLetter(runes[pos]) {
+		for pos < len(runes) && unicode.IsLetter(runes[pos]) {
+			pos++
+		}
+		if pos < len(runes) && isDigit(runes[pos]) {
+			digits()
+		}
+	}
+	if isDigit(runes[pos-1]) || runes[pos-1] == '.' {
+		return token{}, 0, errors.Errorf("missing unit in duration %q at position %d", string(runes[start:pos]), start)
+	}
+
+	return token{kind: tokenDuration, text: string(runes[start:pos]), pos: start}, pos, nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isIdentStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
@@ -0,0 +1,66 @@
+package rule
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		source string
+		kinds  []tokenKind
+		texts  []string
+	}{
+		{"cpu.load_5 > 0.8", []tokenKind{tokenIdent, tokenComparison, tokenNumber}, []string{"cpu.load_5", ">", "0.8"}},
+		{"-1.5 <= 1e5", []tokenKind{tokenNumber, tokenComparison, tokenNumber}, []string{"-1.5", "<=", "1e5"}},
+		{"2.5E-3 != 1e+2", []tokenKind{tokenNumber, tokenComparison, tokenNumber}, []string{"2.5E-3", "!=", "1e+2"}},
+		{"for 5m", []tokenKind{tokenFor, tokenDuration}, []string{"for", "5m"}},
+		{"for 1h30m", []tokenKind{tokenFor, tokenDuration}, []string{"for", "1h30m"}},
+		{"for 1.5h", []tokenKind{tokenFor, tokenDuration}, []string{"for", "1.5h"}},
+		{"for 2h45m30s", []tokenKind{tokenFor, tokenDuration}, []string{"for", "2h45m30s"}},
+		{`disk.usage["/"].free`, []tokenKind{tokenIdent, tokenLeftBracket, tokenString, tokenRightBracket, tokenDot, tokenIdent}, []string{"disk.usage", "[", "/", "]", ".", "free"}},
+		{"a && b || !c AND d", []tokenKind{tokenIdent, tokenAnd, tokenIdent, tokenOr, tokenNot, tokenIdent, tokenAnd, tokenIdent}, []string{"a", "&&", "b", "||", "!", "c", "AND", "d"}},
+		{"avg_over(cpu.usage, 10m)", []tokenKind{tokenIdent, tokenLeftParen, tokenIdent, tokenComma, tokenDuration, tokenRightParen}, []string{"avg_over", "(", "cpu.usage", ",", "10m", ")"}},
+	}
+
+	for _, test := range tests {
+		tokens, err := lex(test.source)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		kinds := []tokenKind{}
+		texts := []string{}
+		for _, token := range tokens[:len(tokens)-1] {
+			kinds = append(kinds, token.kind)
+			texts = append(texts, token.text)
+		}
+		if !reflect.DeepEqual(kinds, test.kinds) || !reflect.DeepEqual(texts, test.texts) {
+			t.Errorf("%v: got %v %q, expected %v %q", test.source, kinds, texts, test.kinds, test.texts)
+		}
+		if last := tokens[len(tokens)-1]; last.kind != tokenEOF {
+			t.Errorf("%v: the last token must be the end of the expression", test.source)
+		}
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{`a > 1 and b = 2`, "unexpected character '=' at position 12"},
+		{`disk.usage["/]`, "unterminated string at position 11"},
+		{`a > 1 for 1h30`, "missing unit in duration \"1h30\" at position 10"},
+		{`a > 1e5m`, "invalid number \"1e5m\" at position 4"},
+	}
+
+	for _, test := range tests {
+		_, err := lex(test.source)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.source, err, test.err)
+		}
+	}
+}
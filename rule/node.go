@@ -0,0 +1,210 @@
+package rule
+
+import (
+	"strings"
+	"time"
+	"webup/syshealth/history"
+)
+
+type valueKind int
+
+const (
+	numberKind valueKind = iota
+	boolKind
+)
+
+// node is an element of a compiled expression.
+// eval returns false when the value is unknown (i.e. a missing metric).
+type node interface {
+	kind() valueKind
+	eval(env Env) (interface{}, bool)
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) kind() valueKind {
+	return numberKind
+}
+
+func (n *numberNode) eval(env Env) (interface{}, bool) {
+	return n.value, true
+}
+
+// path references a metric key and the names used to reach a nested value
+// i.e. disk.usage["/"].free
+type path struct {
+	key       string
+	selectors []string
+}
+
+// resolve returns the value referenced by the path in the data.
+// As metric keys contain dots, the longest key matching the beginning of the path is used.
+func (p path) resolve(data map[string]interface{}) (interface{}, []string, bool) {
+	parts := strings.Split(p.key, ".")
+	for i := len(parts); i > 0; i-- {
+		if value, ok := data[strings.Join(parts[:i], ".")]; ok {
+			return value, append(parts[i:], p.selectors...), true
+		}
+	}
+	return nil, nil, false
+}
+
+// walk returns the nested value reached with the selectors
+func walk(value interface{}, selectors []string) (interface{}, bool) {
+	for _, s := range selectors {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = m[s]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// toNumber converts a metric value into a number
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+type metricNode struct {
+	path path
+}
+
+func (n *metricNode) kind() valueKind {
+	return numberKind
+}
+
+func (n *metricNode) eval(env Env) (interface{}, bool) {
+	value, selectors, ok := n.path.resolve(env.Metrics)
+	if !ok {
+		return nil, false
+	}
+	if value, ok = walk(value, selectors); !ok {
+		return nil, false
+	}
+	return toNumber(value)
+}
+
+type functionNode struct {
+	function function
+	path     path
+	window   time.Duration
+}
+
+func (n *functionNode) kind() valueKind {
+	return numberKind
+}
+
+func (n *functionNode) eval(env Env) (interface{}, bool) {
+	keys := map[string]interface{}{}
+	for k, points := range env.History {
+		keys[k] = points
+	}
+
+	raw, selectors, ok := n.path.resolve(keys)
+	if !ok {
+		return nil, false
+	}
+
+	points := history.Since(raw.([]history.Data), n.window)
+	return n.function(points, func(value interface{}) (float64, bool) {
+		if value, ok := walk(value, selectors); ok {
+			return toNumber(value)
+		}
+		return 0, false
+	})
+}
+
+type comparisonNode struct {
+	operator    string
+	left, right node
+}
+
+func (n *comparisonNode) kind() valueKind {
+	return boolKind
+}
+
+func (n *comparisonNode) eval(env Env) (interface{}, bool) {
+	left, ok := n.left.eval(env)
+	if !ok {
+		return nil, false
+	}
+	right, ok := n.right.eval(env)
+	if !ok {
+		return nil, false
+	}
+
+	l, r := left.(float64), right.(float64)
+	switch n.operator {
+	case ">":
+		return l > r, true
+	case ">=":
+		return l >= r, true
+	case "<":
+		return l < r, true
+	case "<=":
+		return l <= r, true
+	case "==":
+		return l == r, true
+	case "!=":
+		return l != r, true
+	}
+	return nil, false
+}
+
+// logicNode is a 'and' or a 'or' between conditions.
+// An unknown condition is ignored when the result can be known without it.
+type logicNode struct {
+	or          bool
+	left, right node
+}
+
+func (n *logicNode) kind() valueKind {
+	return boolKind
+}
+
+func (n *logicNode) eval(env Env) (interface{}, bool) {
+	left, leftKnown := n.left.eval(env)
+	right, rightKnown := n.right.eval(env)
+
+	// value giving the result with a single known operand
+	decisive := n.or
+	if leftKnown && left.(bool) == decisive || rightKnown && right.(bool) == decisive {
+		return decisive, true
+	}
+	if !leftKnown || !rightKnown {
+		return nil, false
+	}
+	return !decisive, true
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) kind() valueKind {
+	return boolKind
+}
+
+func (n *notNode) eval(env Env) (interface{}, bool) {
+	value, ok := n.operand.eval(env)
+	if !ok {
+		return nil, false
+	}
+	return !value.(bool), true
+}
@@ -0,0 +1,273 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// grammar:
+//
+//	condition  := or [ 'for' DURATION ]
+//	or         := and { 'or' and }
+//	and        := unary { 'and' unary }
+//	unary      := 'not' unary | comparison
+//	comparison := operand [ COMPARISON operand ]
+//	operand    := NUMBER | path | IDENT '(' path ',' DURATION ')' | '(' or ')'
+//	path       := IDENT { '[' STRING ']' | '.' IDENT }
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, description string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, unexpected(t, description)
+	}
+	return t, nil
+}
+
+func unexpected(t token, expected string) error {
+	if t.kind == tokenEOF {
+		return errors.Errorf("unexpected end of expression, expected %v", expected)
+	}
+	return errors.Errorf("unexpected %q at position %d, expected %v", t.text, t.pos, expected)
+}
+
+func (p *parser) parseCondition() (node, time.Duration, error) {
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, 0, err
+	}
+	if root.kind() != boolKind {
+		return nil, 0, errors.New("expression must be a condition (i.e. a comparison)")
+	}
+
+	var duration time.Duration
+	if p.peek().kind == tokenFor {
+		p.next()
+		t, err := p.expect(tokenDuration, "a duration")
+		if err != nil {
+			return nil, 0, err
+		}
+		duration, err = parseDuration(t)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if t := p.next(); t.kind != tokenEOF {
+		return nil, 0, unexpected(t, "end of expression")
+	}
+
+	return root, duration, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		t := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(t, left, right); err != nil {
+			return nil, err
+		}
+		left = &logicNode{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		t := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(t, left, right); err != nil {
+			return nil, err
+		}
+		left = &logicNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokenNot {
+		t := p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBool(t, operand); err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenComparison {
+		return left, nil
+	}
+
+	t := p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if left.kind() != numberKind || right.kind() != numberKind {
+		return nil, errors.Errorf("'%v' at position %d must compare numeric values", t.text, t.pos)
+	}
+
+	return &comparisonNode{operator: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number %q at position %d", t.text, t.pos)
+		}
+		return &numberNode{value: value}, nil
+
+	case tokenLeftParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRightParen, "')'"); err != nil {
+			return nil, err
+		}
+		return n, nil
+
+	case tokenIdent:
+		if p.tokens[p.pos+1].kind == tokenLeftParen {
+			return p.parseFunction()
+		}
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		return &metricNode{path: path}, nil
+	}
+
+	return nil, unexpected(t, "a number, a metric or a function")
+}
+
+func (p *parser) parseFunction() (node, error) {
+	name := p.next()
+	f, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, errors.Errorf("unknown function %q at position %d", name.text, name.pos)
+	}
+
+	p.next() // '('
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenComma, "','"); err != nil {
+		return nil, err
+	}
+	t, err := p.expect(tokenDuration, "a duration")
+	if err != nil {
+		return nil, err
+	}
+	window, err := parseDuration(t)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenRightParen, "')'"); err != nil {
+		return nil, err
+	}
+
+	return &functionNode{function: f, path: path, window: window}, nil
+}
+
+// parseDuration parses a duration token, which must be positive
+func parseDuration(t token) (time.Duration, error) {
+	d, err := time.ParseDuration(t.text)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid duration at position %d", t.pos)
+	}
+	if d <= 0 {
+		return 0, errors.Errorf("the duration at position %d must be positive", t.pos)
+	}
+	return d, nil
+}
+
+func (p *parser) parsePath() (path, error) {
+	t, err := p.expect(tokenIdent, "a metric")
+	if err != nil {
+		return path{}, err
+	}
+
+	result := path{key: t.text}
+	for {
+		switch p.peek().kind {
+		case tokenLeftBracket:
+			p.next()
+			s, err := p.expect(tokenString, "a quoted name")
+			if err != nil {
+				return path{}, err
+			}
+			if _, err := p.expect(tokenRightBracket, "']'"); err != nil {
+				return path{}, err
+			}
+			result.selectors = append(result.selectors, s.text)
+		case tokenDot:
+			p.next()
+			s, err := p.expect(tokenIdent, "a name")
+			if err != nil {
+				return path{}, err
+			}
+			result.selectors = append(result.selectors, strings.Split(s.text, ".")...)
+		default:
+			return result, nil
+		}
+	}
+}
+
+func checkBool(t token, operands ...node) error {
+	for _, operand := range operands {
+		if operand.kind() != boolKind {
+			return errors.Errorf("'%v' at position %d must be applied to conditions", t.text, t.pos)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package rule
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/history"
+)
+
+func TestCompileAndEval(t *testing.T) {
+	now := time.Now()
+	env := Env{
+		Metrics: syshealth.Data{
+			"cpu.load_5":  2.0,
+			"cpu.usage":   50.0,
+			"memory.used": 0.0,
+			"disk.usage": map[string]interface{}{
+				"/": map[string]interface{}{"free": 10.0},
+			},
+		},
+		History: map[string][]history.Data{
+			"cpu.usage": {
+				{Date: now.Add(-time.Duration(2) * time.Hour), Value: 100.0},
+				{Date: now.Add(-time.Duration(20) * time.Minute), Value: 40.0},
+				{Date: now.Add(-time.Duration(5) * time.Minute), Value: 60.0},
+			},
+		},
+	}
+
+	tests := []struct {
+		source string
+		result bool
+		For    time.Duration
+	}{
+		// 'and' has precedence over 'or'
+		{"cpu.load_5 > 1 or cpu.usage > 90 and memory.used > 1", true, 0},
+		{"(cpu.load_5 > 1 or cpu.usage > 90) and memory.used > 1", false, 0},
+		// 'not' has precedence over 'and'
+		{"not cpu.load_5 > 1 and cpu.usage > 90", false, 0},
+		{"not (cpu.load_5 > 3 and cpu.usage > 90)", true, 0},
+		{"!(cpu.load_5 < 1) && cpu.usage == 50", true, 0},
+		// nested values
+		{`disk.usage["/"].free < 20`, true, 0},
+		{`disk.usage["/"].missing < 20`, false, 0},
+		// functions over the history window
+		{"avg_over(cpu.usage, 30m) == 50", true, 0},
+		{"max_over(cpu.usage, 1h30m) == 60", true, 0},
+		{"max_over(cpu.usage, 3h) == 100", true, 0},
+		{"min_over(cpu.usage, 1h) == 40", true, 0},
+		// durations
+		{"cpu.usage > 1e1 for 5m", true, time.Duration(5) * time.Minute},
+		{"cpu.usage > 10 for 1h30m", true, time.Duration(90) * time.Minute},
+		// an unknown operand is ignored when the result is known without it
+		{"missing > 1 or cpu.usage > 10", true, 0},
+		{"missing > 1 and cpu.usage > 10", false, 0},
+	}
+
+	for _, test := range tests {
+		condition, err := Compile(test.source)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.source, err)
+			continue
+		}
+		if result := condition.Eval(env); result != test.result {
+			t.Errorf("%v: got %v, expected %v", test.source, result, test.result)
+		}
+		if condition.For != test.For {
+			t.Errorf("%v: got duration %v, expected %v", test.source, condition.For, test.For)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"cpu.usage", "expression must be a condition"},
+		{"cpu.usage > ", "unexpected end of expression, expected a number, a metric or a function"},
+		{"cpu.usage > 1 and", "unexpected end of expression"},
+		{"cpu.usage > 1 2", `unexpected "2" at position 14, expected end of expression`},
+		{"(cpu.usage > 1", "unexpected end of expression, expected ')'"},
+		{"cpu.usage > 1 for 5", `unexpected "5" at position 18, expected a duration`},
+		{"cpu.usage > 1 for 5x", "invalid duration at position 18"},
+		{"cpu.usage > 1 for -5m", "the duration at position 18 must be positive"},
+		{"cpu.usage > 1 for 0s", "the duration at position 18 must be positive"},
+		{"avg_over(cpu.usage, -5m) > 1", "the duration at position 20 must be positive"},
+		{"median(cpu.usage, 5m) > 1", `unknown function "median" at position 0`},
+		{"avg_over(cpu.usage) > 1", `unexpected ")" at position 18, expected ','`},
+		{"avg_over(cpu.usage, 5) > 1", `unexpected "5" at position 20, expected a duration`},
+		{"cpu.usage and memory.used > 1", "'and' at position 10 must be applied to conditions"},
+		{"(cpu.usage > 1) > 2", "'>' at position 16 must compare numeric values"},
+		{`disk.usage[free] > 1`, `unexpected "free" at position 11, expected a quoted name`},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.source)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: got error %v, expected %q", test.source, err, test.err)
+		}
+	}
+}
+
+func TestCompileDefinitions(t *testing.T) {
+	reserved := []string{"cpu.overload", "checks.*"}
+
+	tests := []struct {
+		definitions []definition
+		err         string
+	}{
+		{[]definition{{Name: "cpu.saturated", Warning: "cpu.usage > 90"}}, ""},
+		{[]definition{{Name: "cpu.overload", Warning: "cpu.usage > 90"}}, "rule 'cpu.overload': name is used by a built-in trigger"},
+		{[]definition{{Name: "checks.http", Warning: "cpu.usage > 90"}}, "rule 'checks.http': name is used by a built-in trigger"},
+		{[]definition{{Name: "a", Warning: "cpu.usage > 90"}, {Name: "a", Critical: "cpu.usage > 95"}}, "rule 'a': name is already used"},
+		{[]definition{{Warning: "cpu.usage > 90"}}, "rule #1: a name is required"},
+		{[]definition{{Name: "a"}}, "rule 'a': a warning or a critical condition is required"},
+		{[]definition{{Name: "a", Critical: "cpu.usage >"}}, "rule 'a': invalid critical condition"},
+	}
+
+	for _, test := range tests {
+		_, err := compileDefinitions(test.definitions, reserved)
+		if test.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.definitions, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: got error %v, expected %q", test.definitions, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/history"
+
+	"github.com/pkg/errors"
+)
+
+// Env contains the data used to evaluate conditions
+type Env struct {
+	Metrics syshealth.Data
+	History map[string][]history.Data
+}
+
+// Condition is a compiled expression
+// i.e. `cpu.load_5 > 0.8 and avg_over(cpu.usage, 5m) > 90 for 5m`
+type Condition struct {
+	Source string
+	// For is the duration during which the expression must be true
+	For  time.Duration
+	root node
+}
+
+// Compile parses and validates an expression
+func Compile(source string) (*Condition, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	root, duration, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Condition{Source: source, For: duration, root: root}, nil
+}
+
+// Eval returns true if the expression is true. An expression which cannot
+// be evaluated (i.e. because of a missing metric) is false.
+func (c *Condition) Eval(env Env) bool {
+	value, ok := c.root.eval(env)
+	return ok && value.(bool)
+}
+
+// Rule associates conditions with levels. A missing condition is never true.
+type Rule struct {
+	Name     string
+	Warning  *Condition
+	Critical *Condition
+}
+
+// definition is the representation of a rule in rules files
+type definition struct {
+	Name     string `json:"name"`
+	Warning  string `json:"warning"`
+	Critical string `json:"critical"`
+}
+
+// Load reads and compiles the rules defined in a JSON file:
+//
+//	[
+//	  {"name": "cpu.saturated", "warning": "cpu.load_5 > 0.8 and cpu.usage > 90 for 5m"}
+//	]
+//
+// Names must be unique, and must not match one of the `reserved` patterns (i.e. the keys of built-in triggers).
+func Load(path string, reserved []string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open rules file")
+	}
+	defer f.Close()
+
+	definitions := []definition{}
+	err = json.NewDecoder(f).Decode(&definitions)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse rules file")
+	}
+
+	return compileDefinitions(definitions, reserved)
+}
+
+func compileDefinitions(definitions []definition, reserved []string) ([]Rule, error) {
+	var err error
+	rules := []Rule{}
+	names := map[string]bool{}
+	for i, d := range definitions {
+		if d.Name == "" {
+			return nil, errors.Errorf("rule #%d: a name is required", i+1)
+		}
+		for _, pattern := range reserved {
+			if matched, _ := filepath.Match(pattern, d.Name); matched {
+				return nil, errors.Errorf("rule '%v': name is used by a built-in trigger", d.Name)
+			}
+		}
+		if names[d.Name] {
+			return nil, errors.Errorf("rule '%v': name is already used", d.Name)
+		}
+		names[d.Name] = true
+
+		if d.Warning == "" && d.Critical == "" {
+			return nil, errors.Errorf("rule '%v': a warning or a critical condition is required", d.Name)
+		}
+
+		r := Rule{Name: d.Name}
+		if d.Warning != "" {
+			r.Warning, err = Compile(d.Warning)
+			if err != nil {
+				return nil, errors.Wrapf(err, "rule '%v': invalid warning condition", d.Name)
+			}
+		}
+		if d.Critical != "" {
+			r.Critical, err = Compile(d.Critical)
+			if err != nil {
+				return nil, errors.Wrapf(err, "rule '%v': invalid critical condition", d.Name)
+			}
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,88 @@
+package threshold
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"webup/syshealth"
+	"webup/syshealth/metrics"
+)
+
+// CheckTrigger expands to a trigger for each check run by the agent,
+// so that the state of each check is kept apart (i.e. `checks.http`)
+type CheckTrigger struct {
+}
+
+func (trigger *CheckTrigger) GetKey() key {
+	return key(metrics.CheckPrefix + "*")
+}
+
+// Check returns no level, the level is given by the trigger of each check
+func (trigger *CheckTrigger) Check(in input) syshealth.ThresholdLevel {
+	return syshealth.None
+}
+
+func (*CheckTrigger) Expand(data syshealth.Data) []trigger {
+	triggers := []trigger{}
+	for _, name := range itemNames(data, metrics.CheckPrefix) {
+		triggers = append(triggers, &checkStateTrigger{name: name})
+	}
+	return triggers
+}
+
+// checkStateTrigger is activated when a check is not OK,
+// the level is given by the state of the check
+type checkStateTrigger struct {
+	name string
+}
+
+func (trigger *checkStateTrigger) GetKey() key {
+	return key(metrics.CheckPrefix + trigger.name)
+}
+
+func (trigger *checkStateTrigger) Check(in input) syshealth.ThresholdLevel {
+	state, ok := trigger.state(in.Metrics)
+	if !ok {
+		return syshealth.None
+	}
+	return checkLevel(state)
+}
+
+func (trigger *checkStateTrigger) Describe(in input) string {
+	check, _ := in.Metrics[string(trigger.GetKey())].(map[string]interface{})
+	return fmt.Sprintf("%v: %v - %v", trigger.name, check["status"], check["output"])
+}
+
+func (trigger *checkStateTrigger) state(data syshealth.Data) (int, bool) {
+	check, ok := data[string(trigger.GetKey())].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	state, ok := check["state"].(float64)
+	return int(state), ok
+}
+
+// checkLevel returns the level of a check state, an unknown state
+// (i.e. the check cannot be run) is a warning
+func checkLevel(state int) syshealth.ThresholdLevel {
+	switch state {
+	case metrics.CheckOK:
+		return syshealth.None
+	case metrics.CheckCritical:
+		return syshealth.Critical
+	default:
+		return syshealth.Warning
+	}
+}
+
+// itemNames returns the sorted names of the metrics with the prefix (i.e. `http` for `checks.http`)
+func itemNames(data syshealth.Data, prefix string) []string {
+	names := []string{}
+	for k := range data {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, strings.TrimPrefix(k, prefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,77 @@
+package threshold
+
+import (
+	"testing"
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/history"
+)
+
+func checkMetric(state int, output string) map[string]interface{} {
+	statuses := map[int]string{0: "OK", 1: "WARNING", 2: "CRITICAL", 3: "UNKNOWN"}
+	return map[string]interface{}{"state": float64(state), "status": statuses[state], "output": output}
+}
+
+func TestCheckTrigger(t *testing.T) {
+	data := syshealth.Data{
+		"checks.http": checkMetric(2, "connection refused"),
+		"checks.disk": checkMetric(3, "cannot run"),
+		"checks.ntp":  checkMetric(0, "offset 0.01s"),
+		"cpu.usage":   12.5,
+	}
+
+	expected := []struct {
+		key         key
+		level       syshealth.ThresholdLevel
+		description string
+	}{
+		{"checks.disk", syshealth.Warning, "disk: UNKNOWN - cannot run"},
+		{"checks.http", syshealth.Critical, "http: CRITICAL - connection refused"},
+		{"checks.ntp", syshealth.None, "ntp: OK - offset 0.01s"},
+	}
+
+	triggers := new(CheckTrigger).Expand(data)
+	if len(triggers) != len(expected) {
+		t.Fatalf("got %d triggers, expected %d", len(triggers), len(expected))
+	}
+
+	in := input{Metrics: data}
+	for i, e := range expected {
+		if triggers[i].GetKey() != e.key {
+			t.Errorf("got key %v, expected %v", triggers[i].GetKey(), e.key)
+		}
+		if level := triggers[i].Check(in); level != e.level {
+			t.Errorf("%v: got level %v, expected %v", e.key, level, e.level)
+		}
+		if description := describe(triggers[i], in); description != e.description {
+			t.Errorf("%v: got description %q, expected %q", e.key, description, e.description)
+		}
+	}
+}
+
+func TestWatcherKeepsStateOfEachCheck(t *testing.T) {
+	w := &watcher{
+		triggers:      []trigger{new(CheckTrigger)},
+		fetcher:       func(string) map[string][]history.Data { return nil },
+		stateByServer: map[string]map[key]triggerState{},
+	}
+	server := syshealth.Server{ID: "1", Name: "web"}
+	watch := func(metrics syshealth.Data) map[key]triggerState {
+		w.Watch(syshealth.WatcherData{Server: server, Metrics: metrics, Date: time.Now(), ReceivedAt: time.Now()})
+		return w.stateByServer[server.ID]
+	}
+
+	watch(syshealth.Data{"checks.http": checkMetric(2, ""), "checks.disk": checkMetric(0, "")})
+	states := watch(syshealth.Data{"checks.http": checkMetric(2, ""), "checks.disk": checkMetric(2, "")})
+	if states["checks.http"].Level != syshealth.Critical || states["checks.disk"].Level != syshealth.Critical {
+		t.Fatalf("both checks must be critical: %+v", states)
+	}
+	if !states["checks.disk"].LastChange.After(states["checks.http"].LastChange) {
+		t.Error("a second critical check must be detected as a change")
+	}
+
+	states = watch(syshealth.Data{"checks.http": checkMetric(0, ""), "checks.disk": checkMetric(2, "")})
+	if states["checks.http"].Level != syshealth.None || states["checks.disk"].Level != syshealth.Critical {
+		t.Errorf("a recovered check must not hide the other one: %+v", states)
+	}
+}
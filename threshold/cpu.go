@@ -5,19 +5,19 @@ import "webup/syshealth"
 type CPULoadTrigger struct {
 }
 
+var cpuLoadLevels = levels{
+	Warning:  band{Raise: 0.6, Clear: 0.5},
+	Critical: band{Raise: 0.8, Clear: 0.7},
+}
+
 func (trigger *CPULoadTrigger) GetKey() key {
 	return "cpu.overload"
 }
 
-func (trigger *CPULoadTrigger) Check(metrics syshealth.Data) syshealth.ThresholdLevel {
-	if rawLoad, ok := metrics["cpu.load_5"]; ok {
+func (trigger *CPULoadTrigger) Check(in input) syshealth.ThresholdLevel {
+	if rawLoad, ok := in.Metrics["cpu.load_5"]; ok {
 		if load, ok := rawLoad.(float64); ok {
-			if load >= 0.8 {
-				return syshealth.Critical
-			}
-			if load >= 0.6 {
-				return syshealth.Warning
-			}
+			return cpuLoadLevels.compute(load, in.Level)
 		}
 	}
 	return syshealth.None
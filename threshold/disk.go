@@ -5,21 +5,23 @@ import "webup/syshealth"
 type DiskUsageTrigger struct {
 }
 
+// free space (in GB) of the default partition
+var diskUsageLevels = levels{
+	Warning:  band{Raise: 2.0, Clear: 2.5},
+	Critical: band{Raise: 1.0, Clear: 1.2},
+	Below:    true,
+}
+
 func (trigger *DiskUsageTrigger) GetKey() key {
 	return "disk.usage"
 }
 
-func (trigger *DiskUsageTrigger) Check(metrics syshealth.Data) syshealth.ThresholdLevel {
-	if raw, ok := metrics["disk.usage"]; ok {
+func (trigger *DiskUsageTrigger) Check(in input) syshealth.ThresholdLevel {
+	if raw, ok := in.Metrics["disk.usage"]; ok {
 		if partitions, ok := raw.(map[string]interface{}); ok {
 			if usageForDefaultPartition, ok := partitions["/"].(map[string]interface{}); ok {
 				if free, ok := usageForDefaultPartition["free"].(float64); ok {
-					if free <= 1.0 {
-						return syshealth.Critical
-					}
-					if free <= 2.0 {
-						return syshealth.Warning
-					}
+					return diskUsageLevels.compute(free, in.Level)
 				}
 			}
 		}
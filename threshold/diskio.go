@@ -0,0 +1,36 @@
+package threshold
+
+import "webup/syshealth"
+
+type DiskIOTrigger struct {
+}
+
+// percent of time spent doing I/O by the busiest device
+var diskIOLevels = levels{
+	Warning:  band{Raise: 80.0, Clear: 70.0},
+	Critical: band{Raise: 95.0, Clear: 85.0},
+}
+
+func (trigger *DiskIOTrigger) GetKey() key {
+	return "disk.io"
+}
+
+func (trigger *DiskIOTrigger) Check(in input) syshealth.ThresholdLevel {
+	max := -1.0
+	if raw, ok := in.Metrics["disk.io"]; ok {
+		if devices, ok := raw.(map[string]interface{}); ok {
+			for _, rawDevice := range devices {
+				if device, ok := rawDevice.(map[string]interface{}); ok {
+					if util, ok := device["util"].(float64); ok && util > max {
+						max = util
+					}
+				}
+			}
+		}
+	}
+
+	if max < 0 {
+		return syshealth.None
+	}
+	return diskIOLevels.compute(max, in.Level)
+}
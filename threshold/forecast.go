@@ -0,0 +1,34 @@
+package threshold
+
+import (
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/history"
+)
+
+// DiskFullForecastTrigger is activated when a partition is predicted to be full
+// within the horizon
+type DiskFullForecastTrigger struct {
+	Horizon time.Duration
+}
+
+func (trigger *DiskFullForecastTrigger) GetKey() key {
+	return "disk.full_forecast"
+}
+
+func (trigger *DiskFullForecastTrigger) Check(in input) syshealth.ThresholdLevel {
+
+	// once activated, the forecast must go beyond the horizon by 25% to be cleared
+	horizon := trigger.Horizon
+	if in.Level > syshealth.None {
+		horizon = horizon * 5 / 4
+	}
+
+	for _, remaining := range history.DiskFullForecast(in.History) {
+		if remaining <= horizon {
+			return syshealth.Warning
+		}
+	}
+
+	return syshealth.None
+}
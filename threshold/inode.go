@@ -0,0 +1,40 @@
+package threshold
+
+import "webup/syshealth"
+
+type InodeUsageTrigger struct {
+}
+
+// percent of used inodes of the fullest partition
+var inodeUsageLevels = levels{
+	Warning:  band{Raise: 90.0, Clear: 85.0},
+	Critical: band{Raise: 95.0, Clear: 92.0},
+}
+
+func (trigger *InodeUsageTrigger) GetKey() key {
+	return "disk.inodes"
+}
+
+func (trigger *InodeUsageTrigger) Check(in input) syshealth.ThresholdLevel {
+	max := -1.0
+	if raw, ok := in.Metrics["disk.usage"]; ok {
+		if partitions, ok := raw.(map[string]interface{}); ok {
+			for _, rawPartition := range partitions {
+				if partition, ok := rawPartition.(map[string]interface{}); ok {
+					// some filesystems don't have a fixed number of inodes
+					if total, ok := partition["inodes_total"].(float64); !ok || total == 0 {
+						continue
+					}
+					if percent, ok := partition["inodes_percent"].(float64); ok && percent > max {
+						max = percent
+					}
+				}
+			}
+		}
+	}
+
+	if max < 0 {
+		return syshealth.None
+	}
+	return inodeUsageLevels.compute(max, in.Level)
+}
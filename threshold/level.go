@@ -0,0 +1,42 @@
+package threshold
+
+import "webup/syshealth"
+
+// band is a hysteresis band: a level is raised when the value reaches
+// `Raise`, and is cleared only when the value goes back past `Clear`
+type band struct {
+	Raise float64
+	Clear float64
+}
+
+// levels defines the bands used to compute the warning and critical levels.
+// When `Below` is true, levels are raised when the value goes down (i.e. free space).
+type levels struct {
+	Warning  band
+	Critical band
+	Below    bool
+}
+
+// compute returns the level for the value, using the previous level
+// to know which limit of each band must be applied
+func (l levels) compute(value float64, previous syshealth.ThresholdLevel) syshealth.ThresholdLevel {
+	if l.reached(value, l.Critical, previous >= syshealth.Critical) {
+		return syshealth.Critical
+	}
+	if l.reached(value, l.Warning, previous >= syshealth.Warning) {
+		return syshealth.Warning
+	}
+	return syshealth.None
+}
+
+func (l levels) reached(value float64, b band, active bool) bool {
+	limit := b.Raise
+	if active {
+		limit = b.Clear
+	}
+
+	if l.Below {
+		return value <= limit
+	}
+	return value >= limit
+}
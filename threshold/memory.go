@@ -5,22 +5,31 @@ import "webup/syshealth"
 type MemoryUsageTrigger struct {
 }
 
+// available memory (in GB), only checked when the used percent is high
+var memoryUsageLevels = levels{
+	Warning:  band{Raise: 0.5, Clear: 0.6},
+	Critical: band{Raise: 0.3, Clear: 0.4},
+	Below:    true,
+}
+
+var memoryUsedPercent = band{Raise: 80.0, Clear: 75.0}
+
 func (trigger *MemoryUsageTrigger) GetKey() key {
 	return "memory.usage"
 }
 
-func (trigger *MemoryUsageTrigger) Check(metrics syshealth.Data) syshealth.ThresholdLevel {
+func (trigger *MemoryUsageTrigger) Check(in input) syshealth.ThresholdLevel {
+
+	limit := memoryUsedPercent.Raise
+	if in.Level > syshealth.None {
+		limit = memoryUsedPercent.Clear
+	}
 
-	if rawUsed, ok := metrics["memory.used_percent"]; ok {
-		if used, ok := rawUsed.(float64); ok && used >= 80.0 {
-			if raw, ok := metrics["memory.available"]; ok {
+	if rawUsed, ok := in.Metrics["memory.used_percent"]; ok {
+		if used, ok := rawUsed.(float64); ok && used >= limit {
+			if raw, ok := in.Metrics["memory.available"]; ok {
 				if available, ok := raw.(float64); ok {
-					if available <= 0.3 {
-						return syshealth.Critical
-					}
-					if available <= 0.5 {
-						return syshealth.Warning
-					}
+					return memoryUsageLevels.compute(available, in.Level)
 				}
 			}
 		}
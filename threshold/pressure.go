@@ -0,0 +1,49 @@
+package threshold
+
+import "webup/syshealth"
+
+// PressureTrigger is activated when tasks are stalled waiting for a resource,
+// based on the Pressure Stall Information averaged over 5 minutes
+type PressureTrigger struct {
+	key      key
+	resource string
+	levels   levels
+}
+
+// NewMemoryPressureTrigger returns a trigger for sustained memory pressure
+func NewMemoryPressureTrigger() *PressureTrigger {
+	return &PressureTrigger{
+		key:      "pressure.memory",
+		resource: "memory",
+		levels: levels{
+			Warning:  band{Raise: 10.0, Clear: 8.0},
+			Critical: band{Raise: 25.0, Clear: 20.0},
+		},
+	}
+}
+
+// NewIOPressureTrigger returns a trigger for sustained IO pressure
+func NewIOPressureTrigger() *PressureTrigger {
+	return &PressureTrigger{
+		key:      "pressure.io",
+		resource: "io",
+		levels: levels{
+			Warning:  band{Raise: 20.0, Clear: 15.0},
+			Critical: band{Raise: 40.0, Clear: 30.0},
+		},
+	}
+}
+
+func (trigger *PressureTrigger) GetKey() key {
+	return trigger.key
+}
+
+func (trigger *PressureTrigger) Check(in input) syshealth.ThresholdLevel {
+	if pressure, ok := in.Metrics["pressure."+trigger.resource].(map[string]interface{}); ok {
+		// percent of time some tasks were stalled
+		if some, ok := pressure["some_avg300"].(float64); ok {
+			return trigger.levels.compute(some, in.Level)
+		}
+	}
+	return syshealth.None
+}
@@ -0,0 +1,74 @@
+package threshold
+
+import (
+	"fmt"
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/metrics"
+)
+
+// certificates expiring within this duration raise a warning
+const probeTLSExpiryWarning = time.Duration(14*24) * time.Hour
+
+// ProbeTrigger expands to a trigger for each endpoint probed by the agent,
+// so that the state of each probe is kept apart (i.e. `probes.api`)
+type ProbeTrigger struct {
+}
+
+func (trigger *ProbeTrigger) GetKey() key {
+	return key(metrics.ProbePrefix + "*")
+}
+
+// Check returns no level, the level is given by the trigger of each probe
+func (trigger *ProbeTrigger) Check(in input) syshealth.ThresholdLevel {
+	return syshealth.None
+}
+
+func (*ProbeTrigger) Expand(data syshealth.Data) []trigger {
+	triggers := []trigger{}
+	for _, name := range itemNames(data, metrics.ProbePrefix) {
+		triggers = append(triggers, &probeStateTrigger{name: name})
+	}
+	return triggers
+}
+
+// probeStateTrigger is activated when an endpoint is down (critical)
+// or when its certificate expires soon (warning)
+type probeStateTrigger struct {
+	name string
+}
+
+func (trigger *probeStateTrigger) GetKey() key {
+	return key(metrics.ProbePrefix + trigger.name)
+}
+
+func (trigger *probeStateTrigger) Check(in input) syshealth.ThresholdLevel {
+	level, _ := trigger.issue(in.Metrics)
+	return level
+}
+
+func (trigger *probeStateTrigger) Describe(in input) string {
+	_, description := trigger.issue(in.Metrics)
+	return description
+}
+
+// issue returns the level of the probe and its description
+func (trigger *probeStateTrigger) issue(data syshealth.Data) (syshealth.ThresholdLevel, string) {
+	probe, ok := data[string(trigger.GetKey())].(map[string]interface{})
+	if !ok {
+		return syshealth.None, ""
+	}
+
+	if up, ok := probe["up"].(bool); ok && !up {
+		return syshealth.Critical, fmt.Sprintf("%v: %v is down (%v)", trigger.name, probe["url"], probe["error"])
+	}
+
+	if expiry, ok := probe["tls_expiry"].(string); ok {
+		date, err := time.Parse(time.RFC3339, expiry)
+		if err == nil && date.Sub(time.Now()) < probeTLSExpiryWarning {
+			return syshealth.Warning, fmt.Sprintf("%v: the certificate of %v expires on %v", trigger.name, probe["url"], date.Format("2006-01-02"))
+		}
+	}
+
+	return syshealth.None, ""
+}
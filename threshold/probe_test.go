@@ -0,0 +1,70 @@
+package threshold
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"webup/syshealth"
+)
+
+func TestProbeTrigger(t *testing.T) {
+	soon := time.Now().Add(time.Duration(72) * time.Hour).Format(time.RFC3339)
+	later := time.Now().Add(time.Duration(90*24) * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		metrics     syshealth.Data
+		level       syshealth.ThresholdLevel
+		description string
+	}{
+		{
+			name:    "endpoints are up",
+			metrics: syshealth.Data{"probes.api": map[string]interface{}{"up": true, "url": "https://api", "tls_expiry": later}},
+			level:   syshealth.None,
+		},
+		{
+			name:        "an endpoint is down",
+			metrics:     syshealth.Data{"probes.api": map[string]interface{}{"up": false, "url": "http://api", "error": "status 503"}},
+			level:       syshealth.Critical,
+			description: "api: http://api is down (status 503)",
+		},
+		{
+			name:        "a certificate expires soon",
+			metrics:     syshealth.Data{"probes.api": map[string]interface{}{"up": true, "url": "https://api", "tls_expiry": soon}},
+			level:       syshealth.Warning,
+			description: "api: the certificate of https://api expires on",
+		},
+	}
+
+	for _, test := range tests {
+		triggers := new(ProbeTrigger).Expand(test.metrics)
+		if len(triggers) != 1 || triggers[0].GetKey() != "probes.api" {
+			t.Fatalf("%v: got triggers %v, expected a trigger for probes.api", test.name, triggers)
+		}
+
+		in := input{Metrics: test.metrics}
+		if level := triggers[0].Check(in); level != test.level {
+			t.Errorf("%v: got level %v, expected %v", test.name, level, test.level)
+		}
+		if description := describe(triggers[0], in); !strings.HasPrefix(description, test.description) {
+			t.Errorf("%v: got description %q, expected %q", test.name, description, test.description)
+		}
+	}
+}
+
+func TestProbeTriggerKeepsStateOfEachProbe(t *testing.T) {
+	data := syshealth.Data{
+		"probes.api":   map[string]interface{}{"up": true, "url": "https://api"},
+		"probes.admin": map[string]interface{}{"up": false, "url": "http://admin", "error": "timeout"},
+	}
+
+	levels := map[key]syshealth.ThresholdLevel{}
+	for _, trigger := range new(ProbeTrigger).Expand(data) {
+		levels[trigger.GetKey()] = trigger.Check(input{Metrics: data})
+	}
+
+	expected := map[key]syshealth.ThresholdLevel{"probes.admin": syshealth.Critical, "probes.api": syshealth.None}
+	if len(levels) != len(expected) || levels["probes.admin"] != expected["probes.admin"] || levels["probes.api"] != expected["probes.api"] {
+		t.Errorf("got levels %v, expected %v", levels, expected)
+	}
+}
@@ -0,0 +1,81 @@
+package threshold
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"webup/syshealth"
+)
+
+// ProcessPresenceTrigger is activated when a process counted by the agent
+// is missing (critical) or over-spawned (warning)
+type ProcessPresenceTrigger struct {
+}
+
+func (trigger *ProcessPresenceTrigger) GetKey() key {
+	return "processes.presence"
+}
+
+func (trigger *ProcessPresenceTrigger) Check(in input) syshealth.ThresholdLevel {
+	level := syshealth.None
+	for _, issue := range processIssues(in.Metrics) {
+		if issue.level > level {
+			level = issue.level
+		}
+	}
+	return level
+}
+
+func (trigger *ProcessPresenceTrigger) Describe(in input) string {
+	descriptions := []string{}
+	for _, issue := range processIssues(in.Metrics) {
+		descriptions = append(descriptions, issue.description)
+	}
+	return strings.Join(descriptions, "\n")
+}
+
+type processIssue struct {
+	level       syshealth.ThresholdLevel
+	description string
+}
+
+func processIssues(metrics syshealth.Data) []processIssue {
+	issues := []processIssue{}
+
+	matchers, ok := metrics["processes.matchers"].(map[string]interface{})
+	if !ok {
+		return issues
+	}
+
+	labels := []string{}
+	for label := range matchers {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		m, ok := matchers[label].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		count, ok := m["count"].(float64)
+		if !ok {
+			continue
+		}
+
+		if min, ok := m["min"].(float64); ok && count < min {
+			issues = append(issues, processIssue{
+				level:       syshealth.Critical,
+				description: fmt.Sprintf("%v: %.0f running, %.0f expected at least", label, count, min),
+			})
+		}
+		if max, ok := m["max"].(float64); ok && count > max {
+			issues = append(issues, processIssue{
+				level:       syshealth.Warning,
+				description: fmt.Sprintf("%v: %.0f running, %.0f expected at most", label, count, max),
+			})
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,64 @@
+package threshold
+
+import (
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/rule"
+)
+
+// RuleTrigger is activated according to the conditions of a rule
+type RuleTrigger struct {
+	rule rule.Rule
+	// sinceByServer stores, for each level, the date from which the condition is true
+	sinceByServer map[string]map[syshealth.ThresholdLevel]time.Time
+}
+
+// NewRuleTrigger returns a trigger for the rule
+func NewRuleTrigger(r rule.Rule) *RuleTrigger {
+	return &RuleTrigger{
+		rule:          r,
+		sinceByServer: map[string]map[syshealth.ThresholdLevel]time.Time{},
+	}
+}
+
+func (trigger *RuleTrigger) GetKey() key {
+	return key(trigger.rule.Name)
+}
+
+func (trigger *RuleTrigger) Check(in input) syshealth.ThresholdLevel {
+	env := rule.Env{Metrics: in.Metrics, History: in.History}
+
+	if trigger.holds(in.Server.ID, syshealth.Critical, trigger.rule.Critical, env) {
+		return syshealth.Critical
+	}
+	if trigger.holds(in.Server.ID, syshealth.Warning, trigger.rule.Warning, env) {
+		return syshealth.Warning
+	}
+	return syshealth.None
+}
+
+// holds returns true if the condition is true for the required duration
+func (trigger *RuleTrigger) holds(serverID string, level syshealth.ThresholdLevel, condition *rule.Condition, env rule.Env) bool {
+	if condition == nil {
+		return false
+	}
+
+	since, ok := trigger.sinceByServer[serverID]
+	if !ok {
+		since = map[syshealth.ThresholdLevel]time.Time{}
+		trigger.sinceByServer[serverID] = since
+	}
+
+	if !condition.Eval(env) {
+		delete(since, level)
+		return false
+	}
+
+	start, ok := since[level]
+	if !ok {
+		start = time.Now()
+		since[level] = start
+	}
+
+	return time.Now().Sub(start) >= condition.For
+}
@@ -0,0 +1,39 @@
+package threshold
+
+import (
+	"sort"
+	"strings"
+	"webup/syshealth"
+)
+
+// SystemdUnitTrigger is activated when a systemd unit is in the failed state
+type SystemdUnitTrigger struct {
+}
+
+func (trigger *SystemdUnitTrigger) GetKey() key {
+	return "systemd.failed"
+}
+
+func (trigger *SystemdUnitTrigger) Check(in input) syshealth.ThresholdLevel {
+	if len(failedUnits(in.Metrics)) > 0 {
+		return syshealth.Critical
+	}
+	return syshealth.None
+}
+
+func (trigger *SystemdUnitTrigger) Describe(in input) string {
+	return "failed units: " + strings.Join(failedUnits(in.Metrics), ", ")
+}
+
+func failedUnits(metrics syshealth.Data) []string {
+	failed := []string{}
+	if units, ok := metrics["systemd.units"].(map[string]interface{}); ok {
+		for name, raw := range units {
+			if unit, ok := raw.(map[string]interface{}); ok && unit["active_state"] == "failed" {
+				failed = append(failed, name)
+			}
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
@@ -0,0 +1,80 @@
+package threshold
+
+import (
+	"time"
+	"webup/syshealth"
+	"webup/syshealth/history"
+)
+
+// TrendTrigger is activated when a metric varies too fast, based on
+// the linear regression of its history
+type TrendTrigger struct {
+	key key
+	// history key and reader of the value to check
+	metric string
+	read   history.ValueReader
+	// duration of history taken into account
+	window time.Duration
+	// minimal fit of the regression, to only consider steady variations
+	minFit float64
+	// levels are applied to the variation per hour (or the decrease per hour if `decrease` is true)
+	levels   levels
+	decrease bool
+}
+
+// NewDiskFreeTrendTrigger returns a trigger activated when the free space (in GB)
+// of the default partition decreases too fast
+func NewDiskFreeTrendTrigger() *TrendTrigger {
+	return &TrendTrigger{
+		key:    "disk.free_trend",
+		metric: "disk.free",
+		read:   history.EntryValue("/"),
+		window: time.Duration(30) * time.Minute,
+		levels: levels{
+			Warning:  band{Raise: 2.0, Clear: 1.5},
+			Critical: band{Raise: 5.0, Clear: 4.0},
+		},
+		decrease: true,
+	}
+}
+
+// NewMemoryGrowthTrigger returns a trigger activated when the used memory (in percent)
+// grows steadily for 30 minutes
+func NewMemoryGrowthTrigger() *TrendTrigger {
+	return &TrendTrigger{
+		key:    "memory.growth",
+		metric: "memory.used_percent",
+		read:   history.FloatValue,
+		window: time.Duration(30) * time.Minute,
+		minFit: 0.8,
+		levels: levels{
+			Warning:  band{Raise: 10.0, Clear: 8.0},
+			Critical: band{Raise: 20.0, Clear: 16.0},
+		},
+	}
+}
+
+func (trigger *TrendTrigger) GetKey() key {
+	return trigger.key
+}
+
+func (trigger *TrendTrigger) Check(in input) syshealth.ThresholdLevel {
+	points := history.Since(in.History[trigger.metric], trigger.window)
+
+	// the history must cover most of the window
+	if len(points) == 0 || time.Now().Sub(points[0].Date) < trigger.window*3/4 {
+		return syshealth.None
+	}
+
+	trend, ok := history.NewTrend(points, trigger.read)
+	if !ok || trend.Fit < trigger.minFit {
+		return syshealth.None
+	}
+
+	variation := trend.PerHour()
+	if trigger.decrease {
+		variation = -variation
+	}
+
+	return trigger.levels.compute(variation, in.Level)
+}
@@ -2,16 +2,21 @@ package threshold
 
 import (
 	"log"
+	"sync"
 	"time"
 	"webup/syshealth"
 	"webup/syshealth/alert"
+	"webup/syshealth/history"
+	"webup/syshealth/rule"
 )
 
 type key string
 
 type watcher struct {
-	triggers       []trigger
-	stateByTrigger map[key]triggerState
+	triggers      []trigger
+	fetcher       history.DataFetcher
+	stateByServer map[string]map[key]triggerState
+	mutex         sync.Mutex
 }
 
 type triggerState struct {
@@ -19,6 +24,9 @@ type triggerState struct {
 	LastSentAlert time.Time
 	AlertCount    int
 	Level         syshealth.ThresholdLevel
+	// Changes contains the dates of the level changes during the flapping window
+	Changes  []time.Time
+	Flapping bool
 }
 
 func (state *triggerState) reset() {
@@ -27,30 +35,135 @@ func (state *triggerState) reset() {
 	state.AlertCount = 0
 }
 
+// isFlapping records the changes and returns true if the level changes too often
+func (state *triggerState) isFlapping(changed bool) bool {
+	now := time.Now()
+	if changed {
+		state.Changes = append(state.Changes, now)
+	}
+
+	// forget changes outside of the window
+	for len(state.Changes) > 0 && now.Sub(state.Changes[0]) > flapWindow {
+		state.Changes = state.Changes[1:]
+	}
+
+	if state.Flapping {
+		return len(state.Changes) > flapStopCount
+	}
+	return len(state.Changes) >= flapStartCount
+}
+
+// input contains data needed by triggers to compute a level
+type input struct {
+	Server  syshealth.Server
+	Metrics syshealth.Data
+	// History contains the metrics history of the server
+	History map[string][]history.Data
+	// Level is the level computed by the previous check
+	Level syshealth.ThresholdLevel
+}
+
 // trigger is a definition of a trigger for a specific metric key
 type trigger interface {
 	GetKey() key
-	Check(metrics syshealth.Data) syshealth.ThresholdLevel
+	Check(in input) syshealth.ThresholdLevel
+}
+
+// expander is implemented by triggers defined for each item of the metrics (i.e. each check),
+// the triggers of the items are checked instead, so that the state of each item is kept apart
+type expander interface {
+	Expand(data syshealth.Data) []trigger
+}
+
+// describer is implemented by triggers able to give details about their level
+type describer interface {
+	Describe(in input) string
+}
+
+// describe returns the details given by the trigger, if any
+func describe(t trigger, in input) string {
+	if d, ok := t.(describer); ok {
+		return d.Describe(in)
+	}
+	return ""
 }
 
 const maxCountForAlerts = 3
 
-// NewWatcher returns a watcher for metrics threshold
-func NewWatcher() syshealth.Watcher {
-	w := watcher{
-		triggers: []trigger{
-			new(CPULoadTrigger),
-			new(MemoryUsageTrigger),
-			new(DiskUsageTrigger),
-		},
+const (
+	flapWindow = time.Duration(10) * time.Minute
+	// a trigger starts flapping when its level changes at least `flapStartCount` times during the window
+	flapStartCount = 5
+	// and stops flapping when there is `flapStopCount` changes or less during the window
+	flapStopCount = 2
+)
+
+// Config contains the settings of the threshold watcher
+type Config struct {
+	// DiskFullHorizon is the duration under which a forecast of full disk raises a warning
+	DiskFullHorizon time.Duration
+	// Rules are custom rules, evaluated in addition to the default triggers
+	Rules []rule.Rule
+}
+
+// defaultTriggers returns the built-in triggers
+func defaultTriggers(config Config) []trigger {
+	return []trigger{
+		new(CPULoadTrigger),
+		new(MemoryUsageTrigger),
+		new(DiskUsageTrigger),
+		new(DiskIOTrigger),
+		new(InodeUsageTrigger),
+		new(ProcessPresenceTrigger),
+		new(SystemdUnitTrigger),
+		new(CheckTrigger),
+		new(ProbeTrigger),
+		NewMemoryPressureTrigger(),
+		NewIOPressureTrigger(),
+		NewDiskFreeTrendTrigger(),
+		NewMemoryGrowthTrigger(),
+		&DiskFullForecastTrigger{Horizon: config.DiskFullHorizon},
 	}
+}
 
-	// prepare state storage
-	w.stateByTrigger = map[key]triggerState{}
+// TriggerKeys returns the keys of the built-in triggers, which cannot be used as rule names.
+// The keys of triggers defined for each item are patterns (i.e. `checks.*`).
+func TriggerKeys() []string {
+	keys := []string{}
+	for _, t := range defaultTriggers(Config{}) {
+		keys = append(keys, string(t.GetKey()))
+	}
+	return keys
+}
+
+// expand returns the triggers to check for the metrics, expanders are replaced by the triggers of their items
+func (w *watcher) expand(data syshealth.Data) []trigger {
+	triggers := []trigger{}
 	for _, t := range w.triggers {
-		w.stateByTrigger[t.GetKey()] = triggerState{}
+		if e, ok := t.(expander); ok {
+			triggers = append(triggers, e.Expand(data)...)
+			continue
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers
+}
+
+// NewWatcher returns a watcher for metrics threshold.
+// The fetcher gives access to the history needed by trend triggers.
+func NewWatcher(fetcher history.DataFetcher, config Config) syshealth.Watcher {
+	w := watcher{
+		triggers: defaultTriggers(config),
+		fetcher:  fetcher,
+	}
+
+	for _, r := range config.Rules {
+		w.triggers = append(w.triggers, NewRuleTrigger(r))
 	}
 
+	// prepare state storage
+	w.stateByServer = map[string]map[key]triggerState{}
+
 	return &w
 }
 
@@ -60,14 +173,32 @@ func (w *watcher) GetKey() syshealth.WatcherKey {
 
 func (w *watcher) Watch(data syshealth.WatcherData) {
 
-	for _, t := range w.triggers {
-		result := t.Check(data.Metrics)
+	// replayed metrics do not describe the current state
+	if data.IsHistorical() {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.stateByServer[data.Server.ID]; !ok {
+		w.stateByServer[data.Server.ID] = map[key]triggerState{}
+	}
+	stateByTrigger := w.stateByServer[data.Server.ID]
+
+	serverHistory := w.fetcher(data.Server.ID)
+
+	for _, t := range w.expand(data.Metrics) {
 
 		// get current state
-		state := w.stateByTrigger[t.GetKey()]
+		state := stateByTrigger[t.GetKey()]
+
+		in := input{Server: data.Server, Metrics: data.Metrics, History: serverHistory, Level: state.Level}
+		result := t.Check(in)
 
 		// detect a change
-		if state.Level == syshealth.None && result > syshealth.None || state.Level > syshealth.None && result == syshealth.None {
+		changed := state.Level == syshealth.None && result > syshealth.None || state.Level > syshealth.None && result == syshealth.None
+		if changed {
 			state.reset()
 			log.Printf("%v(%v): change detected\n", t.GetKey(), data.Server.Name)
 		}
@@ -75,6 +206,35 @@ func (w *watcher) Watch(data syshealth.WatcherData) {
 		// update the level
 		state.Level = result
 
+		// detect flapping
+		flapping := state.isFlapping(changed)
+		if flapping != state.Flapping {
+			state.Flapping = flapping
+
+			if flapping {
+				log.Printf("%v(%v): flapping detected\n", t.GetKey(), data.Server.Name)
+
+				err := alert.SendSlackAlert(syshealth.Alert{
+					IssueTitle: string(t.GetKey()),
+					Server:     data.Server,
+					Level:      syshealth.Warning,
+					Flapping:   true,
+					Processes:  syshealth.GetTopProcesses(data.Metrics),
+				})
+				if err != nil {
+					log.Println("cannot send alert:", err)
+				}
+			} else {
+				log.Printf("%v(%v): flapping stopped\n", t.GetKey(), data.Server.Name)
+			}
+		}
+
+		// regular alerts are not sent while the trigger is flapping
+		if state.Flapping {
+			stateByTrigger[t.GetKey()] = state
+			continue
+		}
+
 		// check if the trigger must be activated
 		// - the level must be greater than 'None'
 		// - the level must not have changed for 2 minutes
@@ -92,9 +252,11 @@ func (w *watcher) Watch(data syshealth.WatcherData) {
 			if timeSinceLastAlert >= time.Duration(count*10)*time.Minute {
 				// send alert
 				err := alert.SendSlackAlert(syshealth.Alert{
-					IssueTitle: string(t.GetKey()),
-					Server:     data.Server,
-					Level:      state.Level,
+					IssueTitle:  string(t.GetKey()),
+					Server:      data.Server,
+					Level:       state.Level,
+					Processes:   syshealth.GetTopProcesses(data.Metrics),
+					Description: describe(t, in),
 				})
 				if err != nil {
 					log.Println("cannot send alert:", err)
@@ -111,6 +273,6 @@ func (w *watcher) Watch(data syshealth.WatcherData) {
 			state.LastChange = time.Now()
 		}
 
-		w.stateByTrigger[t.GetKey()] = state
+		stateByTrigger[t.GetKey()] = state
 	}
 }
@@ -1,11 +1,18 @@
 package syshealth
 
+import "time"
+
 // Data stores metrics identified by key
 type Data map[string]interface{}
 
 // MetricBag is a container used to transport metrics and eventually some metadata
 type MetricBag struct {
 	Metrics Data `json:"metrics"`
+	// Date is the date of the collection, the date of reception is used if empty
+	Date time.Time `json:"date"`
+	// SentAt is the date of the request, used to detect the clock skew of the agent
+	SentAt time.Time  `json:"sent_at"`
+	Agent  *AgentInfo `json:"agent,omitempty"`
 }
 
 // Server represents server data
@@ -13,6 +20,8 @@ type Server struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	IP   string `json:"ip"`
+	// Group allows to share the agent configuration between servers
+	Group string `json:"group"`
 }
 
 // ServerRepository defines the behaviour of the server repository
@@ -25,6 +34,8 @@ type ServerRepository interface {
 	RevokeServer(id string) error
 	// GetServer returns the server associated to the given id, if it is registered
 	GetServer(id string) (*Server, error)
+	// SetServerGroup changes the group of a server
+	SetServerGroup(id string, group string) error
 }
 
 // MetricRepository defines the behaviour of the metric repository
@@ -33,6 +44,23 @@ type MetricRepository interface {
 	Store(serverID string, data Data) error
 }
 
+// AgentRepository defines the behaviour of the agent repository
+type AgentRepository interface {
+	Get(serverID string) (*Agent, error)
+	Store(serverID string, agent Agent) error
+}
+
+// AgentConfigRepository defines the behaviour of the repository of the configurations pushed to agents.
+// Configurations are YAML documents identified by a scope (i.e. `group:web` or `server:<id>`).
+type AgentConfigRepository interface {
+	// GetAll returns the configurations by scope
+	GetAll() (map[string]string, error)
+	// Get returns the configuration of the scope, empty if there is none
+	Get(scope string) (string, error)
+	Set(scope string, config string) error
+	Delete(scope string) error
+}
+
 // AdminUserRepository defines the behaviour of the admin user repository
 type AdminUserRepository interface {
 	IsSetup() (bool, error)
@@ -46,6 +74,24 @@ type AdminUserRepository interface {
 type WatcherData struct {
 	Server  Server
 	Metrics Data
+	// Date is the date of the collection of the metrics
+	Date time.Time
+	// ReceivedAt is the date of reception of the metrics by the server
+	ReceivedAt time.Time
+}
+
+// MaxLiveDelay is the delay after which received metrics are considered as historical
+// (i.e. replayed by an agent after an outage)
+const MaxLiveDelay = time.Duration(2) * time.Minute
+
+// IsHistorical returns true if the metrics were collected too long before their reception
+// to describe the current state. The delay of the watchers to process the metrics is ignored.
+func (d WatcherData) IsHistorical() bool {
+	receivedAt := d.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	return receivedAt.Sub(d.Date) > MaxLiveDelay
 }
 
 // Alert represents data for sending alert
@@ -53,6 +99,12 @@ type Alert struct {
 	IssueTitle string
 	Server     Server
 	Level      ThresholdLevel
+	// Description gives details about the issue, if any
+	Description string
+	// Flapping is true when the alert notifies that the level changes too often
+	Flapping bool
+	// Processes is the snapshot of the top processes when the alert was raised, if available
+	Processes *TopProcesses
 }
 
 // ThresholdLevel represents a level of threshold
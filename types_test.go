@@ -0,0 +1,49 @@
+package syshealth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsHistorical(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		data       WatcherData
+		historical bool
+	}{
+		{"live sample", WatcherData{Date: now.Add(-time.Minute), ReceivedAt: now}, false},
+		{"live sample processed late by watchers", WatcherData{Date: now.Add(-time.Duration(10) * time.Minute), ReceivedAt: now.Add(-time.Duration(9) * time.Minute)}, false},
+		{"replayed sample", WatcherData{Date: now.Add(-time.Duration(10) * time.Minute), ReceivedAt: now}, true},
+		{"no reception date", WatcherData{Date: now.Add(-time.Duration(10) * time.Minute)}, true},
+	}
+
+	for _, test := range tests {
+		if historical := test.data.IsHistorical(); historical != test.historical {
+			t.Errorf("%v: got %v, expected %v", test.name, historical, test.historical)
+		}
+	}
+}
+
+func TestCollectedAt(t *testing.T) {
+	receivedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		bag      MetricBag
+		expected time.Time
+	}{
+		{"no date", MetricBag{}, receivedAt},
+		{"clock of the agent is late", MetricBag{Date: receivedAt.Add(-time.Duration(65) * time.Minute), SentAt: receivedAt.Add(-time.Hour)}, receivedAt.Add(-time.Duration(5) * time.Minute)},
+		{"clock of the agent is early", MetricBag{Date: receivedAt.Add(time.Hour), SentAt: receivedAt.Add(time.Hour)}, receivedAt},
+		{"no request date", MetricBag{Date: receivedAt.Add(-time.Minute)}, receivedAt.Add(-time.Minute)},
+		{"no request date, date in the future", MetricBag{Date: receivedAt.Add(time.Minute)}, receivedAt},
+	}
+
+	for _, test := range tests {
+		if collectedAt := test.bag.CollectedAt(receivedAt); !collectedAt.Equal(test.expected) {
+			t.Errorf("%v: got %v, expected %v", test.name, collectedAt, test.expected)
+		}
+	}
+}
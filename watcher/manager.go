@@ -4,13 +4,20 @@ import (
 	"webup/syshealth"
 )
 
+// count of metrics waiting to be watched by each watcher
+const watcherQueueSize = 1000
+
 type manager struct {
 	watchers []syshealth.Watcher
+	// queues contains the metrics waiting to be watched, by watcher
+	queues []chan syshealth.WatcherData
 }
 
 var man *manager
 
-// Start launches the routine responsible to start and handle watchers
+// Start launches the routine responsible to start and handle watchers.
+// Each watcher runs in a dedicated routine, and watches the metrics in the order
+// they are received, so that replayed metrics are watched in collection order.
 func Start(watchers []syshealth.Watcher) (receivedData chan syshealth.WatcherData) {
 
 	man = new(manager)
@@ -18,16 +25,23 @@ func Start(watchers []syshealth.Watcher) (receivedData chan syshealth.WatcherDat
 	// enable triggers
 	man.watchers = watchers
 
+	for _, w := range man.watchers {
+		queue := make(chan syshealth.WatcherData, watcherQueueSize)
+		man.queues = append(man.queues, queue)
+
+		go func(w syshealth.Watcher, queue chan syshealth.WatcherData) {
+			for data := range queue {
+				w.Watch(data)
+			}
+		}(w, queue)
+	}
+
 	receivedData = make(chan syshealth.WatcherData)
 
 	go func() {
-		for {
-			select {
-			case data := <-receivedData:
-				for _, w := range man.watchers {
-					// execute the `Watch` function in a dedicated thread
-					go w.Watch(data)
-				}
+		for data := range receivedData {
+			for _, queue := range man.queues {
+				queue <- data
 			}
 		}
 	}()
@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"webup/syshealth"
+)
+
+// recorder records the dates of the watched metrics
+type recorder struct {
+	dates []time.Time
+	mutex sync.Mutex
+	wg    *sync.WaitGroup
+}
+
+func (r *recorder) GetKey() syshealth.WatcherKey {
+	return "recorder"
+}
+
+func (r *recorder) Watch(data syshealth.WatcherData) {
+	// watchers take a variable time to watch metrics
+	time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+
+	r.mutex.Lock()
+	r.dates = append(r.dates, data.Date)
+	r.mutex.Unlock()
+	r.wg.Done()
+}
+
+func TestStartKeepsOrder(t *testing.T) {
+	count := 200
+	wg := new(sync.WaitGroup)
+	recorders := []*recorder{{wg: wg}, {wg: wg}}
+	wg.Add(count * len(recorders))
+
+	receivedData := Start([]syshealth.Watcher{recorders[0], recorders[1]})
+
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		receivedData <- syshealth.WatcherData{Date: start.Add(time.Duration(i) * time.Second)}
+	}
+	wg.Wait()
+
+	for _, r := range recorders {
+		for i, date := range r.dates {
+			if expected := start.Add(time.Duration(i) * time.Second); !date.Equal(expected) {
+				t.Fatalf("metrics #%d watched at position %d", int(date.Sub(start).Seconds()), i)
+			}
+		}
+	}
+}